@@ -3,27 +3,22 @@ package main
 import (
 	"context"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	_ "github.com/ruziba3vich/online_compiler_api_gateway/docs"
-	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
 	"github.com/ruziba3vich/online_compiler_api_gateway/internal/db"
 	handler "github.com/ruziba3vich/online_compiler_api_gateway/internal/http"
 	"github.com/ruziba3vich/online_compiler_api_gateway/internal/middleware"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/registry"
 	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
 	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
 	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
 	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
 	logger "github.com/ruziba3vich/prodonik_lgger"
 	limiter "github.com/ruziba3vich/prodonik_rl"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/fx"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"gorm.io/gorm"
 )
 
@@ -38,16 +33,18 @@ func main() {
 			NewLogger,
 			NewDB,
 			handler.NewLangHandler,
-			newPythonGRPCClient,
-			newJavaGRPCClient,
-			newCppGRPCClient,
-			newJsGRPCClient,
+			handler.NewAdminHandler,
+			newLanguageStorage,
+			newExecutorRegistry,
 			newService,
+			newJobQueue,
+			newJobs,
 			handler.NewHandler,
+			handler.NewJobHandler,
 			newGinRouter,
 			newHTTPServer,
 		),
-		fx.Invoke(registerRoutes),
+		fx.Invoke(handler.RegisterRoutes),
 		fx.Invoke(startServer),
 	).Run()
 }
@@ -56,59 +53,62 @@ func NewDB(cfg *config.Config) (*gorm.DB, error) {
 	return db.NewDB(cfg.LangStorageFilePath)
 }
 
-func newPythonGRPCClient(cfg *config.Config, logger *lgg.Logger) (repos.Python, error) {
-	conn, err := grpc.NewClient(cfg.PythonService, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		logger.Error("Failed to connect to Python Executor Service", map[string]any{"error": err})
-		return nil, err
-	}
-	logger.Info("Connected to gRPC service", map[string]any{"address": cfg.PythonService})
-	return compiler_service.NewCodeExecutorClient(conn), nil
+func newLanguageStorage(gormDB *gorm.DB) repos.LanguageStorage {
+	return db.NewLanguageStorage(gormDB)
 }
 
-func newJavaGRPCClient(cfg *config.Config, logger *lgg.Logger) (repos.Java, error) {
-	conn, err := grpc.NewClient(cfg.JavaService, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func newExecutorRegistry(lc fx.Lifecycle, cfg *config.Config, logger *lgg.Logger, storage repos.LanguageStorage) (repos.ExecutorRegistry, error) {
+	r, err := registry.NewRegistry(cfg, logger, storage)
 	if err != nil {
-		logger.Error("Failed to connect to Java Executor Service", map[string]any{"error": err})
 		return nil, err
 	}
-	logger.Info("Connected to gRPC service", map[string]any{"address": cfg.JavaService})
-	return compiler_service.NewCodeExecutorClient(conn), nil
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			r.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return r.Close()
+		},
+	})
+	return r, nil
 }
 
-func newCppGRPCClient(cfg *config.Config, logger *lgg.Logger) (repos.Cpp, error) {
-	conn, err := grpc.NewClient(cfg.CppService, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		logger.Error("Failed to connect to Cpp Executor Service", map[string]any{"error": err})
-		return nil, err
-	}
-	logger.Info("Connected to gRPC service", map[string]any{"address": cfg.CppService})
-	return compiler_service.NewCodeExecutorClient(conn), nil
+func newService(lc fx.Lifecycle, cfg *config.Config, logger *lgg.Logger, registry repos.ExecutorRegistry) *service.Service {
+	srv := service.NewService(
+		logger,
+		registry,
+		cfg)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			srv.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			srv.Stop()
+			return nil
+		},
+	})
+	return srv
 }
 
-func newJsGRPCClient(cfg *config.Config, logger *lgg.Logger) (repos.Js, error) {
-	conn, err := grpc.NewClient(cfg.JsService, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		logger.Error("Failed to connect to Js Executor Service", map[string]any{"error": err})
-		return nil, err
-	}
-	logger.Info("Connected to gRPC service", map[string]any{"address": cfg.JsService})
-	return compiler_service.NewCodeExecutorClient(conn), nil
+func newJobQueue(client *redis.Client, cfg *config.Config) repos.JobQueue {
+	return db.NewJobQueue(client, cfg.JobsCfg)
 }
 
-func newService(
-	logger *lgg.Logger,
-	pythonClient repos.Python,
-	javaClient repos.Java,
-	cppClient repos.Cpp,
-	jsClient repos.Js) *service.Service {
-	return service.NewService(
-		&sync.Mutex{},
-		logger,
-		pythonClient,
-		javaClient,
-		cppClient,
-		jsClient)
+func newJobs(lc fx.Lifecycle, cfg *config.Config, logger *lgg.Logger, srv *service.Service, queue repos.JobQueue) *service.Jobs {
+	jobs := service.NewJobs(logger, srv, queue, cfg)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			jobs.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			jobs.Stop()
+			return nil
+		},
+	})
+	return jobs
 }
 
 func newGinRouter() *gin.Engine {
@@ -121,15 +121,6 @@ func newHTTPServer(cfg *config.Config) *http.Server {
 	}
 }
 
-func registerRoutes(router *gin.Engine, handler *handler.Handler, langHandler *handler.LangHandler, middleware *middleware.MidWare) {
-	router.Use(middleware.CORS())
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	r := router.Group("/api/v1")
-	r.Use(middleware.RateLimit())
-	r.GET("/execute", handler.HandleWebSocket)
-	r.GET("/languages", langHandler.GetAllLanguages)
-}
-
 func startServer(lc fx.Lifecycle, server *http.Server, router *gin.Engine, logger *lgg.Logger, cfg *config.Config) {
 	server.Handler = router
 
@@ -168,6 +159,6 @@ func newRateLimiter(cfg *config.Config, clinent *redis.Client) *limiter.TokenBuc
 	return limiter.NewTokenBucketLimiter(clinent, cfg.RLCnfg.MaxTokens, cfg.RLCnfg.RefillRate, cfg.RLCnfg.Window)
 }
 
-func newMiddleware(limiter *limiter.TokenBucketLimiter, logger *logger.Logger) *middleware.MidWare {
-	return middleware.NewMidWare(logger, limiter)
+func newMiddleware(cfg *config.Config, limiter *limiter.TokenBucketLimiter, logger *logger.Logger) *middleware.MidWare {
+	return middleware.NewMidWare(logger, limiter, cfg.AdminToken)
 }
@@ -15,23 +15,335 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/languages": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns every stored language with its executor address, including disabled ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List language executors",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/languages/reload": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Re-reads the languages table immediately instead of waiting for the next poll",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload the executor registry",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/languages/{name}": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Stores the executor settings of a language version and hot-reloads the executor registry. Settings whose executors cannot be built (unknown load balancing strategy, malformed address, language unsupported by local://, unreadable TLS files) are rejected without being stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create or update a language executor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Language name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Executor settings",
+                        "name": "language",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.Language"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Removes a language version and hot-reloads the executor registry",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete a language executor",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Language name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language version",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "post": {
+                "description": "Queues the code to run in the background with stdin as its whole input, for runs too long to wait for. Jobs are kept in Redis and survive gateway restarts; poll /jobs/{id} for the result.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Submit a background job",
+                "parameters": [
+                    {
+                        "description": "Submission",
+                        "name": "job",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "description": "Returns the status of the job and, once it has finished, its output or the error that kept it from running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get a background job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/judge": {
+            "post": {
+                "description": "Runs the code once per test case with its input as stdin, several at once, and responds with the verdict on each case (AC, WA, RE, TLE, OLE, or IE when the gateway could not run it) once all have been judged. Output is compared ignoring trailing whitespace.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Judge code against test cases",
+                "parameters": [
+                    {
+                        "description": "Submission and test cases",
+                        "name": "judge",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.JudgeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.JudgeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
         "/languages": {
             "get": {
-                "description": "Returns language-script pairs",
+                "description": "Returns the metadata of every language (aliases, file extension, editor mode, features, Hello World template), its selectable versions and whether their executors are currently available",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "languages"
                 ],
-                "summary": "Retrieve base Hello World scripts for all supported languages",
+                "summary": "Retrieve all supported languages",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageInfo"
                             }
                         }
                     },
@@ -46,6 +358,723 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/languages/{name}": {
+            "get": {
+                "description": "Returns the metadata of a language looked up by its name or any of its aliases (e.g. \"py\", \"c++\", \"node\")",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "languages"
+                ],
+                "summary": "Retrieve one language",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Language name or alias",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/run": {
+            "post": {
+                "description": "Runs the code with stdin as its whole input and responds once it has ended, with its collected output, exit status and timings. The output and time limits of the language apply, as for /execute.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Run code to completion",
+                "parameters": [
+                    {
+                        "description": "Submission",
+                        "name": "run",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/runs": {
+            "post": {
+                "description": "Starts running the code like a run message on /execute and returns the session to read its events from, for clients that cannot use WebSockets. The session waits for its event stream for SSE_GRACE.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Start an execution",
+                "parameters": [
+                    {
+                        "description": "Submission",
+                        "name": "run",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunStarted"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/runs/{id}/events": {
+            "get": {
+                "description": "Streams the typed protocol events of the session as server-sent events, whose id is the event's seq. Reconnecting with the Last-Event-ID header (or last_seq) replays the events missed. The stream ends after the exit event.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Stream the events of an execution",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Replay the events after this seq",
+                        "name": "last_seq",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replay the events after this seq",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/runs/{id}/input": {
+            "post": {
+                "description": "Writes to the stdin of the session's run, and closes it when eof is set",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Send input to an execution",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Input",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        },
+        "/runs/{id}/stop": {
+            "post": {
+                "description": "Kills the session's run, whose exit event then reports the reason \"stopped\"",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Stop an execution",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "language"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "stdin": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                },
+                "exit_code": {
+                    "description": "null when unknown, always for remote executors",
+                    "type": "integer"
+                },
+                "limit": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunLimit"
+                },
+                "output_bytes": {
+                    "type": "integer"
+                },
+                "output_lines": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "completed, failed, truncated or timeout",
+                    "type": "string"
+                },
+                "stderr": {
+                    "type": "string"
+                },
+                "stdout": {
+                    "type": "string"
+                },
+                "ttfb_ms": {
+                    "type": "integer"
+                },
+                "wall_time_ms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.Job": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "set when failed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                        }
+                    ]
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "result": {
+                    "description": "set when completed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.ExecutionResult"
+                        }
+                    ]
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "queued, running, completed or failed",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.JudgeRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "language",
+                "tests"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "tests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TestCase"
+                    }
+                },
+                "time_limit_ms": {
+                    "description": "per test case, may only lower JUDGE_TIME_LIMIT",
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.JudgeResult": {
+            "type": "object",
+            "properties": {
+                "passed": {
+                    "type": "integer"
+                },
+                "tests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TestVerdict"
+                    }
+                },
+                "time_ms": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "verdict": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.Language": {
+            "type": "object",
+            "required": [
+                "executor_address"
+            ],
+            "properties": {
+                "default": {
+                    "type": "boolean"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "executor_address": {
+                    "description": "comma separated list of backends",
+                    "type": "string"
+                },
+                "load_balancing": {
+                    "type": "string",
+                    "enum": [
+                        "round_robin",
+                        "least_active"
+                    ]
+                },
+                "tls": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TLS"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageFeatures": {
+            "type": "object",
+            "properties": {
+                "multi_file": {
+                    "type": "boolean"
+                },
+                "stdin": {
+                    "type": "boolean"
+                },
+                "versions": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageInfo": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "available": {
+                    "type": "boolean"
+                },
+                "default_version": {
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "editor_mode": {
+                    "type": "string"
+                },
+                "extension": {
+                    "type": "string"
+                },
+                "features": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageFeatures"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "template": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageVersion"
+                    }
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageResponse": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "type": "boolean"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "executor_address": {
+                    "type": "string"
+                },
+                "load_balancing": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "tls": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TLS"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.LanguageVersion": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunInput": {
+            "type": "object",
+            "properties": {
+                "eof": {
+                    "description": "close stdin after Input",
+                    "type": "boolean"
+                },
+                "input": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunLimit": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunRequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "language"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "eof": {
+                    "description": "close stdin after Stdin",
+                    "type": "boolean"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "stdin": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunStarted": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "string"
+                },
+                "run_id": {
+                    "type": "string"
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TLS": {
+            "type": "object",
+            "properties": {
+                "ca_file": {
+                    "type": "string"
+                },
+                "cert_file": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "key_file": {
+                    "type": "string"
+                },
+                "server_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TestCase": {
+            "type": "object",
+            "properties": {
+                "expected": {
+                    "type": "string"
+                },
+                "input": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.TestVerdict": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/github_com_ruziba3vich_online_compiler_api_gateway_internal_dto.RunError"
+                },
+                "exit_code": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "stderr": {
+                    "type": "string"
+                },
+                "stdout": {
+                    "type": "string"
+                },
+                "test": {
+                    "description": "index in the tests of the request",
+                    "type": "integer"
+                },
+                "time_ms": {
+                    "type": "integer"
+                },
+                "verdict": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
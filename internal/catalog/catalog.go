@@ -0,0 +1,135 @@
+package catalog
+
+import (
+	"slices"
+	"strings"
+)
+
+type (
+	// Language holds the editor facing metadata of a programming language.
+	Language struct {
+		Name        string
+		DisplayName string
+		Aliases     []string
+		Extension   string
+		EditorMode  string
+		Template    string
+		Features    Features
+	}
+
+	// Features lists what the gateway supports for a language.
+	Features struct {
+		Stdin     bool
+		MultiFile bool
+	}
+)
+
+var languages = []Language{
+	{
+		Name:        "python",
+		DisplayName: "Python",
+		Aliases:     []string{"py", "python3"},
+		Extension:   ".py",
+		EditorMode:  "python",
+		Template:    "print(\"Hello, world!\")",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "java",
+		DisplayName: "Java",
+		Extension:   ".java",
+		EditorMode:  "java",
+		Template:    "public class Main {\n    public static void main(String[] args) {\n        System.out.println(\"Hello, world!\");\n    }\n}",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "cpp",
+		DisplayName: "C++",
+		Aliases:     []string{"c++", "cxx", "cc"},
+		Extension:   ".cpp",
+		EditorMode:  "cpp",
+		Template:    "#include <iostream>\nint main() {\n    std::cout << \"Hello, world!\" << std::endl;\n    return 0;\n}",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "javascript",
+		DisplayName: "JavaScript",
+		Aliases:     []string{"js", "node", "nodejs"},
+		Extension:   ".js",
+		EditorMode:  "javascript",
+		Template:    "console.log(\"Hello, world!\");",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "go",
+		DisplayName: "Go",
+		Aliases:     []string{"golang"},
+		Extension:   ".go",
+		EditorMode:  "go",
+		Template:    "package main\nimport \"fmt\"\nfunc main() {\n    fmt.Println(\"Hello, world!\")\n}",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "csharp",
+		DisplayName: "C#",
+		Aliases:     []string{"c#", "cs", "dotnet"},
+		Extension:   ".cs",
+		EditorMode:  "csharp",
+		Template:    "using System;\nclass Program {\n    static void Main() {\n        Console.WriteLine(\"Hello, world!\");\n    }\n}",
+		Features:    Features{Stdin: true},
+	},
+	{
+		Name:        "php",
+		DisplayName: "PHP",
+		Extension:   ".php",
+		EditorMode:  "php",
+		Template:    "<?php\necho \"Hello, world!\";",
+		Features:    Features{Stdin: true},
+	},
+}
+
+// index maps every lowercase name and alias to its position in languages.
+var index = func() map[string]int {
+	index := make(map[string]int)
+	for i, language := range languages {
+		index[language.Name] = i
+		for _, alias := range language.Aliases {
+			index[alias] = i
+		}
+	}
+	return index
+}()
+
+// Resolve returns the canonical name of a language name or alias.
+// Unknown names are returned lowercased and trimmed so that executors registered
+// under names missing from the catalog are still reachable.
+func Resolve(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i, ok := index[name]; ok {
+		return languages[i].Name
+	}
+	return name
+}
+
+// Lookup returns the metadata of a language by name or alias.
+func Lookup(name string) (Language, bool) {
+	i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Language{}, false
+	}
+	return clone(languages[i]), true
+}
+
+// All returns the metadata of every known language.
+func All() []Language {
+	all := make([]Language, 0, len(languages))
+	for _, language := range languages {
+		all = append(all, clone(language))
+	}
+	return all
+}
+
+func clone(language Language) Language {
+	language.Aliases = slices.Clone(language.Aliases)
+	return language
+}
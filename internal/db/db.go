@@ -17,5 +17,12 @@ func NewDB(path string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// languages used to be unique by name only, which does not allow several versions
+	if db.Migrator().HasIndex(&models.Language{}, "idx_languages_name") {
+		if err := db.Migrator().DropIndex(&models.Language{}, "idx_languages_name"); err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+)
+
+// jobGroup is the consumer group of the gateways' workers on the jobs stream.
+const jobGroup = "workers"
+
+// jobBlock bounds how long Next waits for new jobs before looking for abandoned ones again.
+const jobBlock = 2 * time.Second
+
+// JobQueue keeps background jobs in Redis: each job is a JSON value under "<stream>:<id>" and its
+// id is queued on a stream read by the consumer group of the workers. A job stays pending for the
+// gateway that read it until it is acknowledged, so a gateway restarting under the same consumer
+// name runs its pending jobs again, and the jobs of a gateway that never comes back are claimed by
+// another one once they have not been touched for ClaimAfter.
+type JobQueue struct {
+	client *redis.Client
+	cfg    *config.Jobs
+
+	mu        sync.Mutex
+	ready     bool
+	recovered bool
+	pending   []redis.XMessage
+}
+
+func NewJobQueue(client *redis.Client, cfg *config.Jobs) *JobQueue {
+	return &JobQueue{client: client, cfg: cfg}
+}
+
+// Push stores the job and queues it. A job that could not be queued is removed again, rather
+// than left queued forever.
+func (q *JobQueue) Push(ctx context.Context, job *models.Job) error {
+	if err := q.prepare(ctx); err != nil {
+		return err
+	}
+	if err := q.Save(ctx, job); err != nil {
+		return err
+	}
+	err := q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.cfg.Stream, Values: map[string]any{"id": job.ID}}).Err()
+	if err != nil {
+		q.client.Del(context.WithoutCancel(ctx), q.key(job.ID))
+	}
+	return err
+}
+
+// Get returns the stored job.
+func (q *JobQueue) Get(ctx context.Context, id string) (*models.Job, error) {
+	payload, err := q.client.Get(ctx, q.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, repos.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job models.Job
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Save stores the job, which expires TTL after this update.
+func (q *JobQueue) Save(ctx context.Context, job *models.Job) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.client.Set(ctx, q.key(job.ID), payload, q.cfg.TTL).Err()
+}
+
+// Next waits for a job to run: first the jobs this consumer left pending before a restart, then
+// the jobs abandoned by other consumers, then new jobs.
+func (q *JobQueue) Next(ctx context.Context) (*models.Job, error) {
+	if err := q.prepare(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := q.receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		id, _ := msg.Values["id"].(string)
+		job, err := q.Get(ctx, id)
+		if errors.Is(err, repos.ErrJobNotFound) {
+			// the job expired while it was queued, or its entry was deleted
+			q.ack(ctx, msg.ID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		job.Receipt = msg.ID
+		return job, nil
+	}
+}
+
+// Touch resets the idle time of the job, which this consumer is still running, so that other
+// consumers do not claim it.
+func (q *JobQueue) Touch(ctx context.Context, job *models.Job) error {
+	return q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   q.cfg.Stream,
+		Group:    jobGroup,
+		Consumer: q.cfg.Consumer,
+		Messages: []string{job.Receipt},
+	}).Err()
+}
+
+// Done removes the job from the queue; it stays readable with Get until it expires.
+func (q *JobQueue) Done(ctx context.Context, job *models.Job) error {
+	return q.ack(ctx, job.Receipt)
+}
+
+// receive returns the next message for this consumer, or nil if none arrived in time.
+func (q *JobQueue) receive(ctx context.Context) (*redis.XMessage, error) {
+	q.mu.Lock()
+	if !q.recovered {
+		streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    jobGroup,
+			Consumer: q.cfg.Consumer,
+			Streams:  []string{q.cfg.Stream, "0"},
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			q.mu.Unlock()
+			return nil, err
+		}
+		for _, stream := range streams {
+			q.pending = append(q.pending, stream.Messages...)
+		}
+		q.recovered = true
+	}
+	if len(q.pending) > 0 {
+		msg := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		return &msg, nil
+	}
+	q.mu.Unlock()
+
+	if q.cfg.ClaimAfter > 0 {
+		claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.cfg.Stream,
+			Group:    jobGroup,
+			Consumer: q.cfg.Consumer,
+			MinIdle:  q.cfg.ClaimAfter,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if len(claimed) > 0 {
+			return &claimed[0], nil
+		}
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    jobGroup,
+		Consumer: q.cfg.Consumer,
+		Streams:  []string{q.cfg.Stream, ">"},
+		Count:    1,
+		Block:    jobBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range streams {
+		if len(stream.Messages) > 0 {
+			return &stream.Messages[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// prepare creates the stream and the consumer group of the workers unless they exist.
+func (q *JobQueue) prepare(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ready {
+		return nil
+	}
+	err := q.client.XGroupCreateMkStream(ctx, q.cfg.Stream, jobGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	q.ready = true
+	return nil
+}
+
+func (q *JobQueue) ack(ctx context.Context, receipt string) error {
+	pipe := q.client.TxPipeline()
+	pipe.XAck(ctx, q.cfg.Stream, jobGroup, receipt)
+	pipe.XDel(ctx, q.cfg.Stream, receipt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (q *JobQueue) key(id string) string {
+	return q.cfg.Stream + ":" + id
+}
@@ -0,0 +1,134 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/db"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+)
+
+const claimAfter = time.Minute
+
+// jobQueues starts a Redis server whose clock only moves when the test sets it, and returns it
+// with a queue factory for the gateway named consumer.
+func jobQueues(t *testing.T) (*miniredis.Miniredis, func(consumer string) *db.JobQueue) {
+	t.Helper()
+	server, client := testutil.Redis(t)
+	server.SetTime(time.Now())
+	return server, func(consumer string) *db.JobQueue {
+		return db.NewJobQueue(client, &config.Jobs{Stream: "jobs", Consumer: consumer, ClaimAfter: claimAfter, TTL: time.Hour})
+	}
+}
+
+func push(t *testing.T, q *db.JobQueue, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := q.Push(context.Background(), &models.Job{ID: id, Status: "queued"}); err != nil {
+			t.Fatalf("Push(%s): %v", id, err)
+		}
+	}
+}
+
+func next(t *testing.T, q *db.JobQueue, want string) *models.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if job.ID != want {
+		t.Fatalf("got job %s, want %s", job.ID, want)
+	}
+	return job
+}
+
+func TestJobQueue_RunsJobsInOrder(t *testing.T) {
+	_, queues := jobQueues(t)
+	q := queues("a")
+	push(t, q, "j1", "j2")
+
+	ctx := context.Background()
+	for _, id := range []string{"j1", "j2"} {
+		job := next(t, q, id)
+		if err := q.Done(ctx, job); err != nil {
+			t.Fatalf("Done: %v", err)
+		}
+	}
+	// done jobs stay readable
+	if job, err := q.Get(ctx, "j1"); err != nil || job.ID != "j1" {
+		t.Fatalf("got %+v (%v), want j1 kept", job, err)
+	}
+	if _, err := q.Get(ctx, "unknown"); !errors.Is(err, repos.ErrJobNotFound) {
+		t.Fatalf("got %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestJobQueue_RecoversPendingJobs(t *testing.T) {
+	_, queues := jobQueues(t)
+	push(t, queues("a"), "j1", "j2")
+	next(t, queues("a"), "j1")
+
+	// the gateway restarting under the same name runs its pending job first
+	restarted := queues("a")
+	next(t, restarted, "j1")
+	next(t, restarted, "j2")
+}
+
+func TestJobQueue_ClaimsAbandonedJobs(t *testing.T) {
+	server, queues := jobQueues(t)
+	a, b := queues("a"), queues("b")
+	push(t, a, "j1", "j2", "j3")
+	next(t, a, "j1")
+
+	// a job running on another gateway is left alone
+	if err := b.Done(context.Background(), next(t, b, "j2")); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+
+	// until that gateway has not touched it for ClaimAfter
+	server.SetTime(time.Now().Add(claimAfter + time.Second))
+	next(t, b, "j1")
+	next(t, b, "j3")
+}
+
+func TestJobQueue_TouchedJobsAreNotClaimed(t *testing.T) {
+	server, queues := jobQueues(t)
+	a, b := queues("a"), queues("b")
+	push(t, a, "j1", "j2")
+	running := next(t, a, "j1")
+
+	// a job running for longer than ClaimAfter stays with its gateway as long as it is touched
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		server.SetTime(start.Add(time.Duration(i) * claimAfter / 2))
+		if err := a.Touch(context.Background(), running); err != nil {
+			t.Fatalf("Touch: %v", err)
+		}
+	}
+	next(t, b, "j2")
+}
+
+func TestJobQueue_PushRemovesUnqueuedJob(t *testing.T) {
+	server, queues := jobQueues(t)
+	q := queues("a")
+	push(t, q, "j1")
+
+	// the stream turning into another type makes queuing fail
+	server.Del("jobs")
+	server.Set("jobs", "not a stream")
+	err := q.Push(context.Background(), &models.Job{ID: "j2", Status: "queued"})
+	if err == nil {
+		t.Fatalf("got %v, want the queuing error", err)
+	}
+	if _, err := q.Get(context.Background(), "j2"); !errors.Is(err, repos.ErrJobNotFound) {
+		t.Fatalf("got %v, want the job removed instead of left queued", err)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLanguageNotFound is returned when no row matches the requested language name and version.
+var ErrLanguageNotFound = errors.New("language not found")
+
+// LanguageStorage persists language executor settings in the languages table.
+type LanguageStorage struct {
+	db *gorm.DB
+}
+
+func NewLanguageStorage(db *gorm.DB) *LanguageStorage {
+	return &LanguageStorage{db: db}
+}
+
+// List returns every language row ordered by name and version.
+func (s *LanguageStorage) List(ctx context.Context) ([]models.Language, error) {
+	var languages []models.Language
+	if err := s.db.WithContext(ctx).Order("name").Order("version").Find(&languages).Error; err != nil {
+		return nil, err
+	}
+	return languages, nil
+}
+
+// Get returns the given version of a language.
+func (s *LanguageStorage) Get(ctx context.Context, name, version string) (*models.Language, error) {
+	var language models.Language
+	err := s.db.WithContext(ctx).Where("name = ? AND version = ?", name, version).First(&language).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLanguageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &language, nil
+}
+
+// Upsert inserts the language or overwrites every column of the existing row with the same name and version.
+// Marking a version as default clears the flag on the other versions of the language.
+func (s *LanguageStorage) Upsert(ctx context.Context, language *models.Language) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if language.IsDefault {
+			err := tx.Model(&models.Language{}).
+				Where("name = ? AND version <> ?", language.Name, language.Version).
+				Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}, {Name: "version"}},
+			UpdateAll: true,
+		}).
+			Select("*").
+			Omit("id").
+			Create(language).Error
+	})
+}
+
+// Delete removes the given version of a language.
+func (s *LanguageStorage) Delete(ctx context.Context, name, version string) error {
+	res := s.db.WithContext(ctx).Where("name = ? AND version = ?", name, version).Delete(&models.Language{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrLanguageNotFound
+	}
+	return nil
+}
+
+// Seed inserts the given languages only when the table is still empty.
+func (s *LanguageStorage) Seed(ctx context.Context, languages []models.Language) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Language{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 || len(languages) == 0 {
+			return nil
+		}
+		return tx.Select("*").Omit("id").Create(&languages).Error
+	})
+}
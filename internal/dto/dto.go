@@ -1,8 +1,169 @@
 package dto
 
 type (
+	// Language is the admin payload for creating or updating a language executor.
 	Language struct {
-		Name     string `json:"name"`
-		Password string `json:"password"`
+		DisplayName     string `json:"display_name"`
+		Version         string `json:"version"`
+		Default         bool   `json:"default"`
+		ExecutorAddress string `json:"executor_address" binding:"required"` // comma separated list of backends
+		LoadBalancing   string `json:"load_balancing" binding:"omitempty,oneof=round_robin least_active"`
+		Enabled         *bool  `json:"enabled"`
+		TLS             *TLS   `json:"tls"`
+	}
+
+	// TLS describes how the gateway secures its connections to a language's executors.
+	// Certificate files are re-read from disk whenever they change.
+	TLS struct {
+		Enabled    bool   `json:"enabled"`
+		CAFile     string `json:"ca_file,omitempty"`
+		CertFile   string `json:"cert_file,omitempty"`
+		KeyFile    string `json:"key_file,omitempty"`
+		ServerName string `json:"server_name,omitempty"`
+	}
+
+	// LanguageResponse describes a stored language executor.
+	LanguageResponse struct {
+		Name            string `json:"name"`
+		DisplayName     string `json:"display_name"`
+		Version         string `json:"version"`
+		Default         bool   `json:"default"`
+		ExecutorAddress string `json:"executor_address"`
+		LoadBalancing   string `json:"load_balancing"`
+		Enabled         bool   `json:"enabled"`
+		TLS             TLS    `json:"tls"`
+		UpdatedAt       string `json:"updated_at"`
+	}
+
+	// LanguageInfo is the public description of a language returned by /languages.
+	LanguageInfo struct {
+		Name           string            `json:"name"`
+		DisplayName    string            `json:"display_name"`
+		Aliases        []string          `json:"aliases"`
+		Extension      string            `json:"extension"`
+		EditorMode     string            `json:"editor_mode"`
+		Template       string            `json:"template"`
+		Features       LanguageFeatures  `json:"features"`
+		Available      bool              `json:"available"`
+		DefaultVersion string            `json:"default_version"`
+		Versions       []LanguageVersion `json:"versions"`
+	}
+
+	// LanguageFeatures lists what the gateway supports for a language.
+	LanguageFeatures struct {
+		Stdin     bool `json:"stdin"`
+		MultiFile bool `json:"multi_file"`
+		Versions  bool `json:"versions"`
+	}
+
+	// RunRequest starts an execution over HTTP.
+	RunRequest struct {
+		Language string `json:"language" binding:"required"`
+		Version  string `json:"version"`
+		Code     string `json:"code" binding:"required"`
+		Stdin    string `json:"stdin"`
+		EOF      bool   `json:"eof"` // close stdin after Stdin
+	}
+
+	// RunStarted identifies an execution started over HTTP and where to read its events.
+	RunStarted struct {
+		SessionID string `json:"session_id"`
+		RunID     string `json:"run_id"`
+		Events    string `json:"events"`
+	}
+
+	// RunInput is sent to the stdin of an execution started over HTTP.
+	RunInput struct {
+		Input string `json:"input"`
+		EOF   bool   `json:"eof"` // close stdin after Input
+	}
+
+	// RunError describes why a request about an execution failed.
+	RunError struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+
+	// ExecutionRequest runs code to completion with all of its input.
+	ExecutionRequest struct {
+		Language string `json:"language" binding:"required"`
+		Version  string `json:"version"`
+		Code     string `json:"code" binding:"required"`
+		Stdin    string `json:"stdin"`
+	}
+
+	// ExecutionResult is the output and exit status of an execution run to completion.
+	ExecutionResult struct {
+		Stdout      string    `json:"stdout"`
+		Stderr      string    `json:"stderr"`
+		ExitCode    *int      `json:"exit_code"` // null when unknown, always for remote executors
+		Reason      string    `json:"reason"`    // completed, failed, truncated or timeout
+		WallTimeMs  int64     `json:"wall_time_ms"`
+		TTFBMs      *int64    `json:"ttfb_ms,omitempty"`
+		OutputBytes int64     `json:"output_bytes"`
+		OutputLines int64     `json:"output_lines"`
+		Limit       *RunLimit `json:"limit,omitempty"`
+		Error       *RunError `json:"error,omitempty"`
+	}
+
+	// RunLimit names the output or time limit that stopped an execution.
+	RunLimit struct {
+		Name string `json:"name"`
+		Max  int64  `json:"max"`
+	}
+
+	// Job describes an execution run in the background and, once it has finished, its result.
+	Job struct {
+		ID         string           `json:"id"`
+		Status     string           `json:"status"` // queued, running, completed or failed
+		Attempts   int              `json:"attempts"`
+		CreatedAt  string           `json:"created_at"`
+		StartedAt  string           `json:"started_at,omitempty"`
+		FinishedAt string           `json:"finished_at,omitempty"`
+		Result     *ExecutionResult `json:"result,omitempty"` // set when completed
+		Error      *RunError        `json:"error,omitempty"`  // set when failed
+	}
+
+	// JudgeRequest runs code against test cases.
+	JudgeRequest struct {
+		Language    string     `json:"language" binding:"required"`
+		Version     string     `json:"version"`
+		Code        string     `json:"code" binding:"required"`
+		Tests       []TestCase `json:"tests" binding:"required"`
+		TimeLimitMs int64      `json:"time_limit_ms"` // per test case, may only lower JUDGE_TIME_LIMIT
+	}
+
+	// TestCase is an input of a judged submission and the output expected for it.
+	TestCase struct {
+		Input    string `json:"input"`
+		Expected string `json:"expected"`
+	}
+
+	// TestVerdict is the verdict on one test case, AC, WA, RE, TLE, OLE or IE when it could not run.
+	TestVerdict struct {
+		Test     int       `json:"test"` // index in the tests of the request
+		Verdict  string    `json:"verdict"`
+		TimeMs   int64     `json:"time_ms"`
+		ExitCode *int      `json:"exit_code,omitempty"`
+		Reason   string    `json:"reason,omitempty"`
+		Stdout   string    `json:"stdout"`
+		Stderr   string    `json:"stderr,omitempty"`
+		Error    *RunError `json:"error,omitempty"`
+	}
+
+	// JudgeResult is AC when every test case passed, and otherwise the verdict of the first one that did not.
+	JudgeResult struct {
+		Verdict string        `json:"verdict"`
+		Passed  int           `json:"passed"`
+		Total   int           `json:"total"`
+		TimeMs  int64         `json:"time_ms"`
+		Tests   []TestVerdict `json:"tests"`
+	}
+
+	// LanguageVersion is one selectable version of a language.
+	LanguageVersion struct {
+		Version     string `json:"version"`
+		DisplayName string `json:"display_name"`
+		Available   bool   `json:"available"`
 	}
 )
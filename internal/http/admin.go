@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/db"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+type AdminHandler struct {
+	storage  repos.LanguageStorage
+	registry repos.ExecutorRegistry
+	logger   *lgg.Logger
+}
+
+func NewAdminHandler(storage repos.LanguageStorage, registry repos.ExecutorRegistry, logger *lgg.Logger) *AdminHandler {
+	return &AdminHandler{
+		storage:  storage,
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// ListLanguages godoc
+// @Summary      List language executors
+// @Description  Returns every stored language with its executor address, including disabled ones
+// @Tags         admin
+// @Produce      json
+// @Security     AdminToken
+// @Success      200  {array}   dto.LanguageResponse
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/languages [get]
+func (h *AdminHandler) ListLanguages(c *gin.Context) {
+	languages, err := h.storage.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]dto.LanguageResponse, 0, len(languages))
+	for _, language := range languages {
+		resp = append(resp, toLanguageResponse(&language))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpsertLanguage godoc
+// @Summary      Create or update a language executor
+// @Description  Stores the executor settings of a language version and hot-reloads the executor registry. Settings whose executors cannot be built (unknown load balancing strategy, malformed address, language unsupported by local://, unreadable TLS files) are rejected without being stored.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     AdminToken
+// @Param        name     path      string        true  "Language name"
+// @Param        language body      dto.Language  true  "Executor settings"
+// @Success      200  {object}  dto.LanguageResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/languages/{name} [put]
+func (h *AdminHandler) UpsertLanguage(c *gin.Context) {
+	var req dto.Language
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	language := &models.Language{
+		Name:            strings.ToLower(c.Param("name")),
+		DisplayName:     req.DisplayName,
+		Version:         req.Version,
+		IsDefault:       req.Default,
+		ExecutorAddress: req.ExecutorAddress,
+		LoadBalancing:   req.LoadBalancing,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		UpdatedAt:       time.Now(),
+	}
+	if req.TLS != nil {
+		language.TLSEnabled = req.TLS.Enabled
+		language.TLSCAFile = req.TLS.CAFile
+		language.TLSCertFile = req.TLS.CertFile
+		language.TLSKeyFile = req.TLS.KeyFile
+		language.TLSServerName = req.TLS.ServerName
+	}
+	// a row that cannot be built would be skipped by every reload, so it is not stored
+	if err := h.registry.Check(language); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.storage.Upsert(c.Request.Context(), language); err != nil {
+		h.logger.Error("Failed to store language", map[string]any{"language": language.Name, "error": err})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Info("Language executor updated", map[string]any{"language": language.Name, "version": language.Version, "address": language.ExecutorAddress, "enabled": language.Enabled})
+
+	if err := h.registry.Reload(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toLanguageResponse(language))
+}
+
+// DeleteLanguage godoc
+// @Summary      Delete a language executor
+// @Description  Removes a language version and hot-reloads the executor registry
+// @Tags         admin
+// @Security     AdminToken
+// @Param        name     path   string  true   "Language name"
+// @Param        version  query  string  false  "Language version"
+// @Success      204
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/languages/{name} [delete]
+func (h *AdminHandler) DeleteLanguage(c *gin.Context) {
+	name, version := strings.ToLower(c.Param("name")), c.Query("version")
+	if err := h.storage.Delete(c.Request.Context(), name, version); err != nil {
+		if errors.Is(err, db.ErrLanguageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Info("Language executor deleted", map[string]any{"language": name, "version": version})
+
+	if err := h.registry.Reload(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ReloadLanguages godoc
+// @Summary      Reload the executor registry
+// @Description  Re-reads the languages table immediately instead of waiting for the next poll
+// @Tags         admin
+// @Security     AdminToken
+// @Success      204
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/languages/reload [post]
+func (h *AdminHandler) ReloadLanguages(c *gin.Context) {
+	if err := h.registry.Reload(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func toLanguageResponse(language *models.Language) dto.LanguageResponse {
+	return dto.LanguageResponse{
+		Name:            language.Name,
+		DisplayName:     language.DisplayName,
+		Version:         language.Version,
+		Default:         language.IsDefault,
+		ExecutorAddress: language.ExecutorAddress,
+		LoadBalancing:   language.LoadBalancing,
+		Enabled:         language.Enabled,
+		TLS: dto.TLS{
+			Enabled:    language.TLSEnabled,
+			CAFile:     language.TLSCAFile,
+			CertFile:   language.TLSCertFile,
+			KeyFile:    language.TLSKeyFile,
+			ServerName: language.TLSServerName,
+		},
+		UpdatedAt: language.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,103 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/registry"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+)
+
+// adminGateway starts a gateway whose executors are loaded by a registry from its language storage.
+func adminGateway(t *testing.T) (*testutil.Gateway, *registry.Registry) {
+	t.Helper()
+	cfg := testutil.Config()
+	cfg.Executors = map[string]string{"python": "127.0.0.1:7001"}
+	storage := testutil.Storage(t)
+	r, err := registry.NewRegistry(cfg, testutil.Logger(), storage)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	r.Start()
+	return testutil.NewGatewayWith(t, cfg, testutil.Backends{Registry: r, Storage: storage}), r
+}
+
+func adminRequest(t *testing.T, method, url string, body any) (int, map[string]any) {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req, _ := http.NewRequest(method, url, &payload)
+	req.Header.Set("Authorization", "Bearer "+testutil.AdminToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestAdmin_UpsertLanguage(t *testing.T) {
+	gw, r := adminGateway(t)
+
+	status, body := adminRequest(t, http.MethodPut, gw.URL("/admin/languages/Go"), map[string]any{"executor_address": "127.0.0.1:7002, 127.0.0.1:7003", "load_balancing": "least_active"})
+	if status != http.StatusOK || body["name"] != "go" {
+		t.Fatalf("got %d %v, want the language stored", status, body)
+	}
+	executors := r.Executors()
+	if len(executors) != 2 || executors[0].Language != "go" || len(executors[0].Backends) != 2 {
+		t.Fatalf("got executors %+v, want go added with its two backends", executors)
+	}
+}
+
+func TestAdmin_RejectsInvalidLanguage(t *testing.T) {
+	gw, r := adminGateway(t)
+
+	cases := []map[string]any{
+		{"executor_address": "127.0.0.1:7002", "tls": map[string]any{"enabled": true, "ca_file": "/missing/ca.pem"}},
+		{"executor_address": "127.0.0.1:7002", "tls": map[string]any{"enabled": true, "cert_file": "/missing/cert.pem"}},
+		{"executor_address": "127.0.0.1"},
+		{"executor_address": "127.0.0.1:7002", "load_balancing": "random"},
+	}
+	for _, body := range cases {
+		if status, resp := adminRequest(t, http.MethodPut, gw.URL("/admin/languages/go"), body); status != http.StatusBadRequest {
+			t.Errorf("PUT %v: got %d %v, want 400", body, status, resp)
+		}
+	}
+	if status, resp := adminRequest(t, http.MethodPut, gw.URL("/admin/languages/cobol"), map[string]any{"executor_address": "local://"}); status != http.StatusBadRequest {
+		t.Errorf("PUT cobol on local://: got %d %v, want 400", status, resp)
+	}
+
+	// nothing was stored, so the registry keeps loading
+	rows, err := gw.Storage.List(context.Background())
+	if err != nil || len(rows) != 1 || rows[0].Name != "python" {
+		t.Fatalf("got rows %+v (%v), want only the seeded one", rows, err)
+	}
+	if status, _ := adminRequest(t, http.MethodPost, gw.URL("/admin/languages/reload"), nil); status != http.StatusNoContent {
+		t.Fatalf("got %d, want the reload to succeed", status)
+	}
+	if n := len(r.Executors()); n != 1 {
+		t.Fatalf("got %d executors, want 1", n)
+	}
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	gw, _ := adminGateway(t)
+
+	resp, err := http.Get(gw.URL("/admin/languages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401 without a token", resp.StatusCode)
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
 	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true,
+	Subprotocols:      service.Subprotocols,
 	CheckOrigin: func(r *http.Request) bool {
 		// origin := r.Header.Get("Origin")
 		// return origin == "http://compile.prodonik.uz" || origin == "https://compile.prodonik.uz"
@@ -25,13 +28,15 @@ type (
 	Handler struct {
 		srv    *service.Service
 		logger *lgg.Logger
+		cfg    *config.Config
 	}
 )
 
-func NewHandler(srv *service.Service, logger *lgg.Logger) *Handler {
+func NewHandler(srv *service.Service, logger *lgg.Logger, cfg *config.Config) *Handler {
 	return &Handler{
 		srv:    srv,
 		logger: logger,
+		cfg:    cfg,
 	}
 }
 
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+type JobHandler struct {
+	jobs   *service.Jobs
+	logger *lgg.Logger
+}
+
+func NewJobHandler(jobs *service.Jobs, logger *lgg.Logger) *JobHandler {
+	return &JobHandler{
+		jobs:   jobs,
+		logger: logger,
+	}
+}
+
+// SubmitJob godoc
+// @Summary      Submit a background job
+// @Description  Queues the code to run in the background with stdin as its whole input, for runs too long to wait for. Jobs are kept in Redis and survive gateway restarts; poll /jobs/{id} for the result.
+// @Tags         jobs
+// @Accept       json
+// @Produce      json
+// @Param        job  body      dto.ExecutionRequest  true  "Submission"
+// @Success      202  {object}  dto.Job
+// @Failure      400  {object}  dto.RunError
+// @Failure      503  {object}  dto.RunError
+// @Router       /jobs [post]
+func (h *JobHandler) SubmitJob(c *gin.Context) {
+	var req dto.ExecutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.RunError{Error: err.Error(), Code: service.ErrInvalidMessage})
+		return
+	}
+
+	job, werr := h.jobs.Submit(c.Request.Context(), service.WsMessage{
+		Language: req.Language,
+		Version:  req.Version,
+		Code:     req.Code,
+		Stdin:    req.Stdin,
+	})
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.JSON(http.StatusAccepted, toJob(job))
+}
+
+// GetJob godoc
+// @Summary      Get a background job
+// @Description  Returns the status of the job and, once it has finished, its output or the error that kept it from running
+// @Tags         jobs
+// @Produce      json
+// @Param        id   path      string  true  "Job id"
+// @Success      200  {object}  dto.Job
+// @Failure      404  {object}  dto.RunError
+// @Failure      503  {object}  dto.RunError
+// @Router       /jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, werr := h.jobs.Get(c.Request.Context(), c.Param("id"))
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.JSON(http.StatusOK, toJob(job))
+}
+
+func toJob(job *models.Job) dto.Job {
+	out := dto.Job{
+		ID:        job.ID,
+		Status:    job.Status,
+		Attempts:  job.Attempts,
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+	}
+	if job.StartedAt != nil {
+		out.StartedAt = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.FinishedAt != nil {
+		out.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+	if job.Error != nil {
+		out.Error = &dto.RunError{Error: job.Error.Message, Code: job.Error.Code}
+	}
+	if o := job.Output; o != nil {
+		result := &dto.ExecutionResult{
+			Stdout:      o.Stdout,
+			Stderr:      o.Stderr,
+			ExitCode:    o.ExitCode,
+			Reason:      o.Reason,
+			WallTimeMs:  o.WallTimeMs,
+			TTFBMs:      o.TTFBMs,
+			OutputBytes: o.OutputBytes,
+			OutputLines: o.OutputLines,
+		}
+		if o.LimitName != "" {
+			result.Limit = &dto.RunLimit{Name: o.LimitName, Max: o.LimitMax}
+		}
+		if o.ErrorCode != "" {
+			result.Error = &dto.RunError{Error: o.ErrorText, Code: o.ErrorCode}
+		}
+		out.Result = result
+	}
+	return out
+}
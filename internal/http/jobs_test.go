@@ -0,0 +1,194 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/db"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakequeue"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func submitJob(t *testing.T, gw *testutil.Gateway, body any) (int, dto.Job, dto.RunError) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	resp, err := http.Post(gw.URL("/jobs"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var job dto.Job
+	var runErr dto.RunError
+	json.Unmarshal(raw, &job)
+	json.Unmarshal(raw, &runErr)
+	return resp.StatusCode, job, runErr
+}
+
+func getJob(t *testing.T, gw *testutil.Gateway, id string) (int, dto.Job) {
+	t.Helper()
+	resp, err := http.Get(gw.URL("/jobs/" + id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var job dto.Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	return resp.StatusCode, job
+}
+
+// awaitJob polls the job until it reaches status.
+func awaitJob(t *testing.T, gw *testutil.Gateway, id, status string) dto.Job {
+	t.Helper()
+	deadline := time.Now().Add(wstest.DefaultTimeout)
+	for {
+		_, job := getJob(t, gw, id)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %q, want %q", id, job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobs_RunToCompletion(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.EchoInput("read: "), fakeexec.Status(service.StateExitCode+"0"))
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	status, job, _ := submitJob(t, gw, map[string]any{"language": "python", "code": "print(input())", "stdin": "42\n"})
+	if status != http.StatusAccepted || job.ID == "" || job.Status != service.JobQueued {
+		t.Fatalf("got %d %+v, want a queued job", status, job)
+	}
+
+	done := awaitJob(t, gw, job.ID, service.JobCompleted)
+	if done.Result == nil || done.Result.Stdout != "read: 42\n" || done.Result.ExitCode == nil || *done.Result.ExitCode != 0 {
+		t.Fatalf("got %+v, want the output of the program", done.Result)
+	}
+	if done.Attempts != 1 || done.StartedAt == "" || done.FinishedAt == "" {
+		t.Fatalf("got %+v, want one attempt with its timestamps", done)
+	}
+}
+
+func TestJobs_SurviveRestart(t *testing.T) {
+	queue := fakequeue.New()
+	cfg := testutil.Config()
+	cfg.JobsCfg.Workers = 1
+
+	stuck := fakeexec.NewServer(t)
+	stuck.SetScript(fakeexec.Hang())
+	first := testutil.NewGatewayWith(t, cfg, testutil.Backends{Registry: fakeexec.NewRegistry(fakeexec.Executor("python", stuck)), Queue: queue})
+	_, job, _ := submitJob(t, first, map[string]any{"language": "python", "code": "print(1)"})
+	awaitJob(t, first, job.ID, service.JobRunning)
+	stuck.WaitOpen(1, wstest.DefaultTimeout)
+
+	// the gateway going down interrupts the job, which stays pending
+	first.Jobs.Stop()
+	stuck.WaitIdle(wstest.DefaultTimeout)
+	if n := queue.Pending(); n != 1 {
+		t.Fatalf("got %d pending job(s), want the interrupted one", n)
+	}
+
+	queue.Requeue()
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("1\n"))
+	second := testutil.NewGatewayWith(t, cfg, testutil.Backends{Registry: fakeexec.NewRegistry(fakeexec.Executor("python", exec)), Queue: queue})
+	done := awaitJob(t, second, job.ID, service.JobCompleted)
+	if done.Attempts != 2 || done.Result == nil || done.Result.Stdout != "1\n" {
+		t.Fatalf("got %+v, want the job completed on its second attempt", done)
+	}
+	if n := queue.Pending(); n != 0 {
+		t.Fatalf("got %d pending job(s), want none", n)
+	}
+}
+
+func TestJobs_GivesUpAfterMaxAttempts(t *testing.T) {
+	queue := fakequeue.New()
+	cfg := testutil.Config()
+	cfg.JobsCfg.MaxAttempts = 1
+	gw := testutil.NewGatewayWith(t, cfg, testutil.Backends{Registry: fakeexec.NewRegistry(fakeexec.Executor("python", fakeexec.NewServer(t))), Queue: queue})
+
+	queue.Push(context.Background(), &models.Job{ID: "j1", Status: service.JobRunning, Language: "python", Code: "print(1)", Attempts: 1})
+	done := awaitJob(t, gw, "j1", service.JobFailed)
+	if done.Error == nil || done.Error.Code != service.ErrJobAbandoned {
+		t.Fatalf("got %+v, want %s", done.Error, service.ErrJobAbandoned)
+	}
+}
+
+func TestJobs_Errors(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	cases := []struct {
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{map[string]any{"language": "cobol", "code": "DISPLAY 1"}, http.StatusBadRequest, service.ErrUnsupportedLanguage},
+		{map[string]any{"language": "python", "code": "import os"}, http.StatusBadRequest, service.ErrDangerousCode},
+		{map[string]any{"language": "python"}, http.StatusBadRequest, service.ErrInvalidMessage},
+	}
+	for _, tc := range cases {
+		if status, _, runErr := submitJob(t, gw, tc.body); status != tc.status || runErr.Code != tc.code {
+			t.Errorf("POST %v: got %d %+v, want %d %s", tc.body, status, runErr, tc.status, tc.code)
+		}
+	}
+	if status, _ := getJob(t, gw, "unknown"); status != http.StatusNotFound {
+		t.Fatalf("got %d, want 404 for an unknown job", status)
+	}
+}
+
+func TestJobs_RedisQueue_TakesOverAbandonedJobs(t *testing.T) {
+	_, client := testutil.Redis(t)
+	gateway := func(consumer string, registry *fakeexec.Registry) *testutil.Gateway {
+		cfg := testutil.Config()
+		cfg.JobsCfg.Workers = 1
+		cfg.JobsCfg.Consumer = consumer
+		cfg.JobsCfg.ClaimAfter = 300 * time.Millisecond
+		cfg.JobsCfg.MaxAttempts = 1
+		return testutil.NewGatewayWith(t, cfg, testutil.Backends{Registry: registry, Queue: db.NewJobQueue(client, cfg.JobsCfg)})
+	}
+
+	stuck := fakeexec.NewServer(t)
+	stuck.SetScript(fakeexec.Hang())
+	first := gateway("a", fakeexec.NewRegistry(fakeexec.Executor("python", stuck)))
+	_, job, _ := submitJob(t, first, map[string]any{"language": "python", "code": "print(1)"})
+	awaitJob(t, first, job.ID, service.JobRunning)
+	stuck.WaitOpen(1, wstest.DefaultTimeout)
+
+	// a job running for longer than JOBS_CLAIM_AFTER is not taken over while its gateway is up
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("2\n"))
+	second := gateway("b", fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	time.Sleep(time.Second)
+	// the other gateway looks for abandoned jobs before taking each new one
+	for range 2 {
+		_, other, _ := submitJob(t, second, map[string]any{"language": "python", "code": "print(2)"})
+		awaitJob(t, second, other.ID, service.JobCompleted)
+	}
+	if _, running := getJob(t, second, job.ID); running.Status != service.JobRunning || running.Attempts != 1 {
+		t.Fatalf("got %+v, want the job still running its first attempt", running)
+	}
+	if n := exec.Sessions(); n != 2 {
+		t.Fatalf("got %d execution(s) on the other gateway, want only its own 2 jobs", n)
+	}
+
+	// once its gateway is gone, the job is taken over and given up after JOBS_MAX_ATTEMPTS
+	first.Jobs.Stop()
+	done := awaitJob(t, second, job.ID, service.JobFailed)
+	if done.Error == nil || done.Error.Code != service.ErrJobAbandoned {
+		t.Fatalf("got %+v, want %s", done.Error, service.ErrJobAbandoned)
+	}
+}
@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+)
+
+func postJudge(t *testing.T, gw *testutil.Gateway, body any) (int, dto.JudgeResult, dto.RunError) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	resp, err := http.Post(gw.URL("/judge"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var result dto.JudgeResult
+	var runErr dto.RunError
+	json.Unmarshal(raw, &result)
+	json.Unmarshal(raw, &runErr)
+	return resp.StatusCode, result, runErr
+}
+
+// adderScript prints the sum of the two numbers of its input after delay.
+func adderScript(exec *fakeexec.Server, delay time.Duration) {
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.Delay(delay), fakeexec.ByInput(func(input string) []fakeexec.Step {
+		var a, b int
+		fmt.Sscan(input, &a, &b)
+		return []fakeexec.Step{fakeexec.Output(fmt.Sprintf("%d  \n\n", a+b)), fakeexec.Status(service.StateExitCode + "0")}
+	}))
+}
+
+func TestJudge_ConcurrencyLimit(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	adderScript(exec, 50*time.Millisecond)
+	cfg := testutil.Config()
+	cfg.JudgeCfg.Concurrency = 2
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	tests := make([]map[string]string, 4)
+	for i := range tests {
+		tests[i] = map[string]string{"input": fmt.Sprintf("%d 1\n", i), "expected": fmt.Sprint(i + 1)}
+	}
+	status, result, _ := postJudge(t, gw, map[string]any{"language": "python", "code": "print(sum(map(int, input().split())))", "tests": tests})
+	if status != http.StatusOK || result.Verdict != service.VerdictAccepted || result.Passed != 4 || len(result.Tests) != 4 {
+		t.Fatalf("got %d %+v, want every test accepted", status, result)
+	}
+	// two rounds of two tests that take 50ms each
+	if result.TimeMs < 100 {
+		t.Fatalf("got the tests judged in %dms, want them run two at a time", result.TimeMs)
+	}
+	for i, v := range result.Tests {
+		if v.Test != i || v.Stdout != fmt.Sprintf("%d  \n\n", i+1) {
+			t.Fatalf("got %+v for test %d", v, i)
+		}
+	}
+}
+
+func TestJudge_Errors(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	cfg := testutil.Config()
+	cfg.JudgeCfg.MaxTests = 2
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	one := []map[string]string{{"input": "", "expected": ""}}
+	cases := []struct {
+		body map[string]any
+		code string
+	}{
+		{map[string]any{"language": "python", "code": "print(1)", "tests": []map[string]string{}}, service.ErrInvalidMessage},
+		{map[string]any{"language": "python", "code": "print(1)", "tests": append(one, one[0], one[0])}, service.ErrInvalidMessage},
+		{map[string]any{"language": "python", "code": "import os", "tests": one}, service.ErrDangerousCode},
+		{map[string]any{"language": "cobol", "code": "DISPLAY 1", "tests": one}, service.ErrUnsupportedLanguage},
+	}
+	for _, tc := range cases {
+		if status, _, runErr := postJudge(t, gw, tc.body); status != http.StatusBadRequest || runErr.Code != tc.code {
+			t.Errorf("POST %v: got %d %+v, want 400 %s", tc.body, status, runErr, tc.code)
+		}
+	}
+	if n := exec.Sessions(); n != 0 {
+		t.Fatalf("got %d execution(s), want none for rejected submissions", n)
+	}
+}
@@ -3,40 +3,102 @@
 // @description API for managing programming languages and compiling code
 // @host compile.prodonik.uz
 // @BasePath /api/v1
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
 package handler
 
 import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/catalog"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
 	logger "github.com/ruziba3vich/prodonik_lgger"
 )
 
 type LangHandler struct {
+	srv *service.Service
 }
 
-func NewLangHandler(logger *logger.Logger) *LangHandler {
-	return &LangHandler{}
+func NewLangHandler(srv *service.Service, logger *logger.Logger) *LangHandler {
+	return &LangHandler{srv: srv}
 }
 
 // GetAllLanguages godoc
-// @Summary      Retrieve base Hello World scripts for all supported languages
-// @Description  Returns language-script pairs
+// @Summary      Retrieve all supported languages
+// @Description  Returns the metadata of every language (aliases, file extension, editor mode, features, Hello World template), its selectable versions and whether their executors are currently available
 // @Tags         languages
 // @Produce      json
-// @Success      200  {object}  map[string]string
+// @Success      200  {object}  map[string]dto.LanguageInfo
 // @Failure      500  {object}  map[string]string
 // @Router       /languages [get]
 func (h *LangHandler) GetAllLanguages(c *gin.Context) {
-	c.JSON(http.StatusOK, baseScripts)
+	statuses := h.srv.Languages()
+
+	languages := make(map[string]dto.LanguageInfo, len(statuses))
+	for _, language := range catalog.All() {
+		languages[language.Name] = toLanguageInfo(language, statuses[language.Name])
+	}
+	for name, status := range statuses {
+		if _, ok := languages[name]; !ok {
+			languages[name] = toLanguageInfo(catalog.Language{Name: name, DisplayName: name}, status)
+		}
+	}
+	c.JSON(http.StatusOK, languages)
+}
+
+// GetLanguage godoc
+// @Summary      Retrieve one language
+// @Description  Returns the metadata of a language looked up by its name or any of its aliases (e.g. "py", "c++", "node")
+// @Tags         languages
+// @Produce      json
+// @Param        name  path      string  true  "Language name or alias"
+// @Success      200  {object}  dto.LanguageInfo
+// @Failure      404  {object}  map[string]string
+// @Router       /languages/{name} [get]
+func (h *LangHandler) GetLanguage(c *gin.Context) {
+	name := catalog.Resolve(c.Param("name"))
+	status, registered := h.srv.Languages()[name]
+
+	language, ok := catalog.Lookup(name)
+	if !ok {
+		if !registered {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Language not found"})
+			return
+		}
+		language = catalog.Language{Name: name, DisplayName: name}
+	}
+	c.JSON(http.StatusOK, toLanguageInfo(language, status))
 }
 
-var baseScripts map[string]string = map[string]string{
-	"java":       "public class Main {\n    public static void main(String[] args) {\n        System.out.println(\"Hello, world!\");\n    }\n}",
-	"cpp":        "#include <iostream>\nint main() {\n    std::cout << \"Hello, world!\" << std::endl;\n    return 0;\n}",
-	"go":         "package main\nimport \"fmt\"\nfunc main() {\n    fmt.Println(\"Hello, world!\")\n}",
-	"javascript": "console.log(\"Hello, world!\");",
-	"csharp":     "using System;\nclass Program {\n    static void Main() {\n        Console.WriteLine(\"Hello, world!\");\n    }\n}",
-	"php":        "<?php\necho \"Hello, world!\";",
-	"python":     "print(\"Hello, world!\")",
+func toLanguageInfo(language catalog.Language, status service.LanguageStatus) dto.LanguageInfo {
+	info := dto.LanguageInfo{
+		Name:        language.Name,
+		DisplayName: language.DisplayName,
+		Aliases:     language.Aliases,
+		Extension:   language.Extension,
+		EditorMode:  language.EditorMode,
+		Template:    language.Template,
+		Features: dto.LanguageFeatures{
+			Stdin:     language.Features.Stdin,
+			MultiFile: language.Features.MultiFile,
+			Versions:  len(status.Versions) > 1,
+		},
+		Available:      status.Available,
+		DefaultVersion: status.DefaultVersion,
+		Versions:       make([]dto.LanguageVersion, 0, len(status.Versions)),
+	}
+	if info.Aliases == nil {
+		info.Aliases = []string{}
+	}
+	for _, version := range status.Versions {
+		info.Versions = append(info.Versions, dto.LanguageVersion{
+			Version:     version.Version,
+			DisplayName: version.DisplayName,
+			Available:   version.Available,
+		})
+	}
+	return info
 }
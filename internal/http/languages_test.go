@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func getLanguages(t *testing.T, gw *testutil.Gateway) map[string]dto.LanguageInfo {
+	t.Helper()
+	resp, err := http.Get(gw.URL("/languages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var languages map[string]dto.LanguageInfo
+	if err := json.NewDecoder(resp.Body).Decode(&languages); err != nil {
+		t.Fatal(err)
+	}
+	return languages
+}
+
+// awaitAvailable polls /languages until language is reported with available.
+func awaitAvailable(t *testing.T, gw *testutil.Gateway, language string, available bool) dto.LanguageInfo {
+	t.Helper()
+	deadline := time.Now().Add(wstest.DefaultTimeout)
+	for {
+		info := getLanguages(t, gw)[language]
+		if info.Available == available {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %s with available %t, want %t", language, info.Available, available)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLanguages_ReportsAvailability(t *testing.T) {
+	python, cpp := fakeexec.NewServer(t), fakeexec.NewServer(t)
+	cfg := testutil.Config()
+	cfg.HealthCfg.Interval = 10 * time.Millisecond
+	cfg.HealthCfg.FailureThreshold = 1
+	cfg.HealthCfg.OpenTimeout = time.Hour
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", python), fakeexec.Executor("cpp", cpp)))
+
+	awaitAvailable(t, gw, "python", true)
+	cpp.SetServing(false)
+	info := awaitAvailable(t, gw, "cpp", false)
+	if len(info.Versions) != 1 || info.Versions[0].Available {
+		t.Fatalf("got versions %+v, want the only version unavailable", info.Versions)
+	}
+	if !getLanguages(t, gw)["python"].Available {
+		t.Fatal("python became unavailable with cpp")
+	}
+
+	// a successful probe closes the breaker before its timeout
+	cpp.SetServing(true)
+	awaitAvailable(t, gw, "cpp", true)
+}
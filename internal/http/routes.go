@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/middleware"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// RegisterRoutes serves the API of the gateway on router.
+func RegisterRoutes(router *gin.Engine, handler *Handler, langHandler *LangHandler, adminHandler *AdminHandler, jobHandler *JobHandler, middleware *middleware.MidWare) {
+	router.Use(middleware.CORS())
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r := router.Group("/api/v1")
+	r.Use(middleware.RateLimit())
+	r.GET("/execute", handler.HandleWebSocket)
+	r.POST("/run", handler.Run)
+	r.POST("/judge", handler.Judge)
+	r.POST("/runs", handler.StartRun)
+	r.GET("/runs/:id/events", handler.StreamRunEvents)
+	r.POST("/runs/:id/input", handler.SendRunInput)
+	r.POST("/runs/:id/stop", handler.StopRun)
+	r.POST("/jobs", jobHandler.SubmitJob)
+	r.GET("/jobs/:id", jobHandler.GetJob)
+	r.GET("/languages", langHandler.GetAllLanguages)
+	r.GET("/languages/:name", langHandler.GetLanguage)
+
+	admin := r.Group("/admin", middleware.AdminAuth())
+	admin.GET("/languages", adminHandler.ListLanguages)
+	admin.PUT("/languages/:name", adminHandler.UpsertLanguage)
+	admin.DELETE("/languages/:name", adminHandler.DeleteLanguage)
+	admin.POST("/languages/reload", adminHandler.ReloadLanguages)
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+)
+
+// StartRun godoc
+// @Summary      Start an execution
+// @Description  Starts running the code like a run message on /execute and returns the session to read its events from, for clients that cannot use WebSockets. The session waits for its event stream for SSE_GRACE.
+// @Tags         runs
+// @Accept       json
+// @Produce      json
+// @Param        run  body      dto.RunRequest  true  "Submission"
+// @Success      201  {object}  dto.RunStarted
+// @Failure      400  {object}  dto.RunError
+// @Failure      429  {object}  dto.RunError
+// @Failure      503  {object}  dto.RunError
+// @Router       /runs [post]
+func (h *Handler) StartRun(c *gin.Context) {
+	var req dto.RunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.RunError{Error: err.Error(), Code: service.ErrInvalidMessage})
+		return
+	}
+
+	sessionID, runID, werr := h.srv.StartRun(c.Request.Context(), service.WsMessage{
+		Language: req.Language,
+		Version:  req.Version,
+		Code:     req.Code,
+		Stdin:    req.Stdin,
+		EOF:      req.EOF,
+	})
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.JSON(http.StatusCreated, dto.RunStarted{
+		SessionID: sessionID,
+		RunID:     runID,
+		Events:    "/api/v1/runs/" + sessionID + "/events",
+	})
+}
+
+// StreamRunEvents godoc
+// @Summary      Stream the events of an execution
+// @Description  Streams the typed protocol events of the session as server-sent events, whose id is the event's seq. Reconnecting with the Last-Event-ID header (or last_seq) replays the events missed. The stream ends after the exit event.
+// @Tags         runs
+// @Produce      text/event-stream
+// @Param        id             path    string  true   "Session id"
+// @Param        last_seq       query   int     false  "Replay the events after this seq"
+// @Param        Last-Event-ID  header  string  false  "Replay the events after this seq"
+// @Success      200  {string}  string
+// @Failure      404  {object}  dto.RunError
+// @Router       /runs/{id}/events [get]
+func (h *Handler) StreamRunEvents(c *gin.Context) {
+	lastSeq, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	if value := c.Query("last_seq"); value != "" {
+		lastSeq, _ = strconv.ParseUint(value, 10, 64)
+	}
+
+	stream, werr := h.srv.Subscribe(c.Param("id"), lastSeq)
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	var keepalive <-chan time.Time
+	if interval := h.cfg.SSECfg.KeepAlive; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+
+	for {
+		select {
+		case ev := <-stream.Events():
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				h.logger.Error("Error encoding event", map[string]any{"session_id": c.Param("id"), "error": err})
+				continue
+			}
+			if ev.Seq > 0 {
+				fmt.Fprintf(c.Writer, "id: %d\n", ev.Seq)
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", ev.Type, payload)
+			c.Writer.Flush()
+			if stream.Finished(ev) {
+				return
+			}
+		case <-keepalive:
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+			c.Writer.Flush()
+		case <-stream.Done():
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// SendRunInput godoc
+// @Summary      Send input to an execution
+// @Description  Writes to the stdin of the session's run, and closes it when eof is set
+// @Tags         runs
+// @Accept       json
+// @Produce      json
+// @Param        id     path  string        true  "Session id"
+// @Param        input  body  dto.RunInput  true  "Input"
+// @Success      204
+// @Failure      400  {object}  dto.RunError
+// @Failure      404  {object}  dto.RunError
+// @Failure      409  {object}  dto.RunError
+// @Router       /runs/{id}/input [post]
+func (h *Handler) SendRunInput(c *gin.Context) {
+	var req dto.RunInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.RunError{Error: err.Error(), Code: service.ErrInvalidMessage})
+		return
+	}
+
+	msg := service.WsMessage{Type: service.MsgInput, Input: req.Input, EOF: req.EOF}
+	if req.Input == "" && req.EOF {
+		msg.Type = service.MsgEOF
+	}
+	if werr := h.srv.Control(c.Param("id"), msg); werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// StopRun godoc
+// @Summary      Stop an execution
+// @Description  Kills the session's run, whose exit event then reports the reason "stopped"
+// @Tags         runs
+// @Produce      json
+// @Param        id  path  string  true  "Session id"
+// @Success      204
+// @Failure      404  {object}  dto.RunError
+// @Failure      409  {object}  dto.RunError
+// @Router       /runs/{id}/stop [post]
+func (h *Handler) StopRun(c *gin.Context) {
+	if werr := h.srv.Control(c.Param("id"), service.WsMessage{Type: service.MsgStop}); werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Run godoc
+// @Summary      Run code to completion
+// @Description  Runs the code with stdin as its whole input and responds once it has ended, with its collected output, exit status and timings. The output and time limits of the language apply, as for /execute.
+// @Tags         runs
+// @Accept       json
+// @Produce      json
+// @Param        run  body      dto.ExecutionRequest  true  "Submission"
+// @Success      200  {object}  dto.ExecutionResult
+// @Failure      400  {object}  dto.RunError
+// @Failure      429  {object}  dto.RunError
+// @Failure      502  {object}  dto.RunError
+// @Failure      503  {object}  dto.RunError
+// @Router       /run [post]
+func (h *Handler) Run(c *gin.Context) {
+	var req dto.ExecutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.RunError{Error: err.Error(), Code: service.ErrInvalidMessage})
+		return
+	}
+
+	result, werr := h.srv.Execute(c.Request.Context(), service.WsMessage{
+		Language: req.Language,
+		Version:  req.Version,
+		Code:     req.Code,
+		Stdin:    req.Stdin,
+	})
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+	c.JSON(http.StatusOK, toExecutionResult(result))
+}
+
+func toExecutionResult(result *service.Result) dto.ExecutionResult {
+	out := dto.ExecutionResult{
+		Stdout:      result.Stdout,
+		Stderr:      result.Stderr,
+		ExitCode:    result.ExitCode,
+		Reason:      result.Reason,
+		WallTimeMs:  result.WallTimeMs,
+		TTFBMs:      result.TTFBMs,
+		OutputBytes: result.OutputBytes,
+		OutputLines: result.OutputLines,
+	}
+	if result.Limit != nil {
+		out.Limit = &dto.RunLimit{Name: result.Limit.Name, Max: result.Limit.Max}
+	}
+	if result.Error != nil {
+		out.Error = &dto.RunError{Error: result.Error.Message, Code: result.Error.Code}
+	}
+	return out
+}
+
+// Judge godoc
+// @Summary      Judge code against test cases
+// @Description  Runs the code once per test case with its input as stdin, several at once, and responds with the verdict on each case (AC, WA, RE, TLE, OLE, or IE when the gateway could not run it) once all have been judged. Output is compared ignoring trailing whitespace.
+// @Tags         runs
+// @Accept       json
+// @Produce      json
+// @Param        judge  body      dto.JudgeRequest  true  "Submission and test cases"
+// @Success      200    {object}  dto.JudgeResult
+// @Failure      400    {object}  dto.RunError
+// @Failure      429    {object}  dto.RunError
+// @Router       /judge [post]
+func (h *Handler) Judge(c *gin.Context) {
+	var req dto.JudgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.RunError{Error: err.Error(), Code: service.ErrInvalidMessage})
+		return
+	}
+
+	msg := service.WsMessage{
+		Language:    req.Language,
+		Version:     req.Version,
+		Code:        req.Code,
+		TimeLimitMs: req.TimeLimitMs,
+	}
+	for _, tc := range req.Tests {
+		msg.Tests = append(msg.Tests, service.TestCase{Input: tc.Input, Expected: tc.Expected})
+	}
+	judgement, werr := h.srv.Judge(c.Request.Context(), msg, nil)
+	if werr != nil {
+		runError(c, werr)
+		return
+	}
+
+	result := dto.JudgeResult{
+		Verdict: judgement.Verdict,
+		Passed:  judgement.Passed,
+		Total:   judgement.Total,
+		TimeMs:  judgement.TimeMs,
+	}
+	for _, v := range judgement.Tests {
+		verdict := dto.TestVerdict{
+			Test:     v.Test,
+			Verdict:  v.Verdict,
+			TimeMs:   v.TimeMs,
+			ExitCode: v.ExitCode,
+			Reason:   v.Reason,
+			Stdout:   v.Stdout,
+			Stderr:   v.Stderr,
+		}
+		if v.Error != nil {
+			verdict.Error = &dto.RunError{Error: v.Error.Message, Code: v.Error.Code}
+		}
+		result.Tests = append(result.Tests, verdict)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// runError responds with werr and the HTTP status matching its code.
+func runError(c *gin.Context, werr *service.WsError) {
+	status := http.StatusBadRequest
+	switch werr.Code {
+	case service.ErrSessionNotFound, service.ErrJobNotFound:
+		status = http.StatusNotFound
+	case service.ErrNoActiveRun, service.ErrInputClosed:
+		status = http.StatusConflict
+	case service.ErrTooManyRuns:
+		status = http.StatusTooManyRequests
+	case service.ErrExecutorUnavailable, service.ErrQueueUnavailable:
+		status = http.StatusServiceUnavailable
+	case service.ErrInputFailed:
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, dto.RunError{Error: werr.Message, Code: werr.Code})
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/dto"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func runSync(t *testing.T, gw *testutil.Gateway, body any) (int, dto.ExecutionResult, dto.RunError) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	resp, err := http.Post(gw.URL("/run"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var result dto.ExecutionResult
+	var runErr dto.RunError
+	json.Unmarshal(raw, &result)
+	json.Unmarshal(raw, &runErr)
+	return resp.StatusCode, result, runErr
+}
+
+func TestRunSync_CollectsOutput(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.ReadToEOF(),
+		fakeexec.EchoInput("read: "),
+		fakeexec.Output("done\n"),
+		fakeexec.Stderr("warn\n"),
+		fakeexec.Status(service.StateExitCode+"3"),
+	)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	status, result, _ := runSync(t, gw, map[string]any{"language": "py", "code": "print(sys.stdin.read())", "stdin": "1 2\n"})
+	if status != http.StatusOK {
+		t.Fatalf("got %d, want 200", status)
+	}
+	if result.Stdout != "read: 1 2\ndone\n" || result.Stderr != "warn\n" {
+		t.Fatalf("got stdout %q and stderr %q", result.Stdout, result.Stderr)
+	}
+	if result.ExitCode == nil || *result.ExitCode != 3 || result.Reason != service.ExitCompleted {
+		t.Fatalf("got exit code %v and reason %q, want 3 and %s", result.ExitCode, result.Reason, service.ExitCompleted)
+	}
+	if result.TTFBMs == nil || result.OutputBytes != int64(len("read: 1 2\ndone\nwarn\n")) || result.OutputLines != 3 {
+		t.Fatalf("got %+v, want the timings and output counts", result)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestRunSync_Limits(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("tick\n"), fakeexec.Hang())
+	cfg := testutil.Config()
+	cfg.RunCfg.WallTime = map[string]time.Duration{"*": 100 * time.Millisecond}
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	status, result, _ := runSync(t, gw, map[string]any{"language": "python", "code": "while True: pass"})
+	if status != http.StatusOK || result.Reason != service.ExitTimeout || result.Stdout != "tick\n" {
+		t.Fatalf("got %d %+v, want the output until the timeout", status, result)
+	}
+	if result.Limit == nil || result.Limit.Name != service.LimitWallTime || result.Limit.Max != 100 {
+		t.Fatalf("got limit %+v, want %s of 100", result.Limit, service.LimitWallTime)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestRunSync_Errors(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	cases := []struct {
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{map[string]any{"language": "cobol", "code": "DISPLAY 1"}, http.StatusBadRequest, service.ErrUnsupportedLanguage},
+		{map[string]any{"language": "python", "code": "import os"}, http.StatusBadRequest, service.ErrDangerousCode},
+		{map[string]any{"code": "print(1)"}, http.StatusBadRequest, service.ErrInvalidMessage},
+	}
+	for _, tc := range cases {
+		if status, _, runErr := runSync(t, gw, tc.body); status != tc.status || runErr.Code != tc.code {
+			t.Errorf("POST %v: got %d %+v, want %d %s", tc.body, status, runErr, tc.status, tc.code)
+		}
+	}
+	if n := exec.Sessions(); n != 0 {
+		t.Fatalf("got %d execution(s), want none for rejected runs", n)
+	}
+}
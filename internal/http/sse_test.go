@@ -0,0 +1,225 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+type sseEvent struct {
+	id    string
+	event service.WsEvent
+}
+
+// sseStream reads the server-sent events of a run started over HTTP.
+type sseStream struct {
+	t      *testing.T
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func openEvents(t *testing.T, gw *testutil.Gateway, sessionID, lastEventID string) *sseStream {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, gw.URL("/runs/"+sessionID+"/events"), nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got %d %s, want an event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return &sseStream{t: t, body: resp.Body, reader: bufio.NewReader(resp.Body)}
+}
+
+// next returns the next event, or false when the stream ended.
+func (s *sseStream) next() (sseEvent, bool) {
+	s.t.Helper()
+	var ev sseEvent
+	var data string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return ev, false
+		}
+		if err != nil {
+			s.t.Fatalf("read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != "":
+			if err := json.Unmarshal([]byte(data), &ev.event); err != nil {
+				s.t.Fatalf("decode %s: %v", data, err)
+			}
+			return ev, true
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// until returns the first event of type typ, failing the test if the stream ends before.
+func (s *sseStream) until(typ string) sseEvent {
+	s.t.Helper()
+	for {
+		ev, ok := s.next()
+		if !ok {
+			s.t.Fatalf("event stream ended before a %q event", typ)
+		}
+		if ev.event.Type == typ {
+			return ev
+		}
+	}
+}
+
+func TestSSE_RunWithInput(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("Name?"),
+		fakeexec.AwaitInput(),
+		fakeexec.EchoInput("Hello "),
+	)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	status, started := postJSON(t, gw.URL("/runs"), map[string]any{"language": "python", "code": "print(input())"})
+	if status != http.StatusCreated || started["session_id"] == "" || started["run_id"] == "" {
+		t.Fatalf("got %d %v, want a started run", status, started)
+	}
+	base := gw.URL("/runs/" + started["session_id"])
+
+	events := openEvents(t, gw, started["session_id"], "")
+	if ev := events.until(service.EventQueued); ev.event.RunID != started["run_id"] {
+		t.Fatalf("got %+v, want run %s", ev.event, started["run_id"])
+	}
+	prompt := events.until(service.EventOutput)
+	if prompt.event.Output != "Name?" || prompt.id == "" {
+		t.Fatalf("got %+v, want the prompt with an event id", prompt)
+	}
+
+	if status, body := postJSON(t, base+"/input", map[string]any{"input": "Bob"}); status != http.StatusNoContent {
+		t.Fatalf("got %d %v, want input accepted", status, body)
+	}
+	if ev := events.until(service.EventOutput); ev.event.Output != "Hello Bob" {
+		t.Fatalf("got %+v, want the echoed input", ev.event)
+	}
+	if ev := events.until(service.EventExit); ev.event.Reason != service.ExitCompleted {
+		t.Fatalf("got %+v, want a completed exit", ev.event)
+	}
+	if ev, ok := events.next(); ok {
+		t.Fatalf("got %+v, want the stream to end after the exit event", ev.event)
+	}
+
+	if status, body := postJSON(t, base+"/stop", nil); status != http.StatusConflict || body["code"] != service.ErrNoActiveRun {
+		t.Fatalf("got %d %v, want %s", status, body, service.ErrNoActiveRun)
+	}
+
+	// reconnecting replays what came after the last event seen
+	replay := openEvents(t, gw, started["session_id"], prompt.id)
+	if ev := replay.until(service.EventOutput); ev.event.Output != "Hello Bob" {
+		t.Fatalf("got %+v, want the replayed output", ev.event)
+	}
+	replay.until(service.EventExit)
+}
+
+func TestSSE_StopRun(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	_, started := postJSON(t, gw.URL("/runs"), map[string]any{"language": "python", "code": "print(1)"})
+	events := openEvents(t, gw, started["session_id"], "")
+	events.until(service.EventQueued)
+
+	if status, body := postJSON(t, gw.URL("/runs/"+started["session_id"]+"/stop"), nil); status != http.StatusNoContent {
+		t.Fatalf("got %d %v, want the run stopped", status, body)
+	}
+	if ev := events.until(service.EventExit); ev.event.Reason != service.ExitStopped {
+		t.Fatalf("got %+v, want a stopped exit", ev.event)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestSSE_ReplaysMoreEventsThanTheQueueHolds(t *testing.T) {
+	const lines = 40
+	exec := fakeexec.NewServer(t)
+	steps := make([]fakeexec.Step, lines)
+	for i := range steps {
+		steps[i] = fakeexec.Output(strconv.Itoa(i))
+	}
+	exec.SetScript(steps...)
+	cfg := testutil.Config()
+	cfg.WsCfg.QueueSize = 8
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	// the run ends before anyone subscribes, leaving all its events buffered; closing its stdin
+	// is harmless and tells when it has ended
+	_, started := postJSON(t, gw.URL("/runs"), map[string]any{"language": "python", "code": "print(1)"})
+	deadline := time.Now().Add(wstest.DefaultTimeout)
+	for {
+		_, body := postJSON(t, gw.URL("/runs/"+started["session_id"]+"/input"), map[string]any{"eof": true})
+		if body["code"] == service.ErrNoActiveRun {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the run did not end")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	events := openEvents(t, gw, started["session_id"], "")
+	for i := range lines {
+		if ev := events.until(service.EventOutput); ev.event.Output != strconv.Itoa(i) {
+			t.Fatalf("got %+v, want output %d", ev.event, i)
+		}
+	}
+	if ev := events.until(service.EventExit); ev.event.Reason != service.ExitCompleted {
+		t.Fatalf("got %+v, want a completed exit", ev.event)
+	}
+}
+
+func TestSSE_Errors(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	cases := []struct {
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{map[string]any{"language": "cobol", "code": "DISPLAY 1"}, http.StatusBadRequest, service.ErrUnsupportedLanguage},
+		{map[string]any{"language": "python", "code": "import os"}, http.StatusBadRequest, service.ErrDangerousCode},
+		{map[string]any{"language": "python"}, http.StatusBadRequest, service.ErrInvalidMessage},
+	}
+	for _, tc := range cases {
+		if status, body := postJSON(t, gw.URL("/runs"), tc.body); status != tc.status || body["code"] != tc.code {
+			t.Errorf("POST %v: got %d %v, want %d %s", tc.body, status, body, tc.status, tc.code)
+		}
+	}
+
+	resp, err := http.Get(gw.URL("/runs/unknown/events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %d, want 404 for an unknown session", resp.StatusCode)
+	}
+	if n := exec.Sessions(); n != 0 {
+		t.Fatalf("got %d execution(s), want none for rejected runs", n)
+	}
+}
@@ -0,0 +1,54 @@
+// Package localexec runs submissions as local subprocesses behind the same streaming
+// interface as the remote gRPC executors, so the gateway can be developed offline.
+package localexec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/catalog"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"google.golang.org/grpc"
+)
+
+// Scheme prefixes executor addresses that are served by this package instead of a remote service.
+const Scheme = "local://"
+
+// Status states sent by the local executor. RUNNING and EXECUTION_COMPLETE follow the remote executors.
+const (
+	StateRunning  = "RUNNING"
+	StateComplete = "EXECUTION_COMPLETE"
+	// StateExitCode is followed by the process exit code, e.g. "EXIT_CODE:1". The remote executors
+	// have no equivalent, their protocol does not carry exit codes.
+	StateExitCode = "EXIT_CODE:"
+)
+
+// Executor implements compiler_service.CodeExecutorClient by running code in a local subprocess.
+type Executor struct {
+	language string
+	tc       toolchain
+	cfg      *config.LocalExec
+	logger   *lgg.Logger
+}
+
+// New returns a local executor for language.
+func New(language string, cfg *config.LocalExec, logger *lgg.Logger) (*Executor, error) {
+	language = catalog.Resolve(language)
+	tc, ok := toolchains[language]
+	if !ok {
+		return nil, fmt.Errorf("local executor does not support language %q", language)
+	}
+	return &Executor{
+		language: language,
+		tc:       tc,
+		cfg:      cfg,
+		logger:   logger,
+	}, nil
+}
+
+// Execute opens a new execution stream. The program is started once the Code message is sent.
+func (e *Executor) Execute(ctx context.Context, _ ...grpc.CallOption) (grpc.BidiStreamingClient[compiler_service.ExecuteRequest, compiler_service.ExecuteResponse], error) {
+	return newStream(ctx, e), nil
+}
@@ -0,0 +1,43 @@
+package localexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+)
+
+// command builds the command for argv, applying resource limits through the shell's ulimit
+// so that they are in place before the program starts. Platform specific settings are added by configureProcess.
+// This is no sandbox: the program can read and write whatever the gateway's user can.
+func command(ctx context.Context, dir string, argv []string, cfg *config.LocalExec) *exec.Cmd {
+	var limits []string
+	if cfg.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", cfg.CPUSeconds))
+	}
+	if cfg.MemoryMB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", cfg.MemoryMB*1024))
+	}
+	if cfg.FileSizeKB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -f %d", cfg.FileSizeKB))
+	}
+	script := strings.Join(append(limits, `exec "$@"`), " && ")
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, "sh"}, argv...)...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"LANG=C.UTF-8",
+		"GOCACHE=" + filepath.Join(dir, ".cache"),
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+	}
+	configureProcess(cmd, cfg)
+	return cmd
+}
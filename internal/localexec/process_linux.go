@@ -0,0 +1,72 @@
+//go:build linux
+
+package localexec
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+var (
+	probeOnce            sync.Once
+	warnOnce             sync.Once
+	namespacesSupported  bool
+	namespacesProbeError error
+)
+
+const namespaceFlags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+	syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+// configureProcess runs the program in its own process group, kills it together with the gateway,
+// and, when enabled and allowed by the kernel, runs it in new user, mount, PID, network, IPC and UTS namespaces.
+// These cut it off from the network and the other processes, but not from the file system: the mount
+// namespace is a copy of the gateway's, which is neither pivoted nor remounted read-only.
+func configureProcess(cmd *exec.Cmd, cfg *config.LocalExec) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if cfg.Namespaces && supportsNamespaces() {
+		cmd.SysProcAttr.Cloneflags = namespaceFlags
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+
+	// kill the whole process group so that children of the program do not outlive it
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+}
+
+// supportsNamespaces checks once whether the kernel lets this process create the namespaces,
+// which fails e.g. when unprivileged user namespaces are disabled.
+func supportsNamespaces() bool {
+	probeOnce.Do(func() {
+		cmd := exec.Command("/bin/sh", "-c", "exit 0")
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  namespaceFlags,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+		}
+		namespacesProbeError = cmd.Run()
+		namespacesSupported = namespacesProbeError == nil
+	})
+	return namespacesSupported
+}
+
+// startProcess starts cmd, warning once when namespaces were requested but are not available.
+func startProcess(cmd *exec.Cmd, cfg *config.LocalExec, logger *lgg.Logger) error {
+	if cfg.Namespaces && !namespacesSupported {
+		warnOnce.Do(func() {
+			logger.Warn("Namespaces are not available, running local executions without them", map[string]any{"error": namespacesProbeError})
+		})
+	}
+	return cmd.Start()
+}
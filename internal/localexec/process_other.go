@@ -0,0 +1,20 @@
+//go:build !linux
+
+package localexec
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+// configureProcess only bounds how long Wait waits for leftover children on platforms without namespaces.
+func configureProcess(cmd *exec.Cmd, cfg *config.LocalExec) {
+	cmd.WaitDelay = time.Second
+}
+
+func startProcess(cmd *exec.Cmd, cfg *config.LocalExec, logger *lgg.Logger) error {
+	return cmd.Start()
+}
@@ -0,0 +1,291 @@
+package localexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// stream is an in-process implementation of the Execute bidirectional stream.
+type stream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	exec   *Executor
+
+	mu        sync.Mutex
+	sessionID string
+	started   bool
+	closed    bool
+	inputs    chan []byte
+
+	responses chan *compiler_service.ExecuteResponse
+}
+
+func newStream(ctx context.Context, e *Executor) *stream {
+	ctx, cancel := context.WithCancel(ctx)
+	return &stream{
+		ctx:       ctx,
+		cancel:    cancel,
+		exec:      e,
+		inputs:    make(chan []byte, 64),
+		responses: make(chan *compiler_service.ExecuteResponse, 64),
+	}
+}
+
+func (s *stream) Send(req *compiler_service.ExecuteRequest) error {
+	if s.ctx.Err() != nil {
+		return io.EOF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch payload := req.Payload.(type) {
+	case *compiler_service.ExecuteRequest_Code:
+		if s.started {
+			return status.Error(codes.FailedPrecondition, "code was already submitted on this stream")
+		}
+		s.started = true
+		s.sessionID = req.SessionId
+		go s.run(payload.Code.SourceCode)
+	case *compiler_service.ExecuteRequest_Input:
+		if s.closed {
+			return status.Error(codes.FailedPrecondition, "stdin is closed")
+		}
+		text := payload.Input.InputText
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		select {
+		case s.inputs <- []byte(text):
+		case <-s.ctx.Done():
+			return io.EOF
+		}
+	default:
+		return status.Error(codes.InvalidArgument, "unknown request payload")
+	}
+	return nil
+}
+
+func (s *stream) Recv() (*compiler_service.ExecuteResponse, error) {
+	// drain what the program produced before reporting the end of the stream
+	select {
+	case resp, ok := <-s.responses:
+		if !ok {
+			return nil, io.EOF
+		}
+		return resp, nil
+	default:
+	}
+
+	select {
+	case resp, ok := <-s.responses:
+		if !ok {
+			return nil, io.EOF
+		}
+		return resp, nil
+	case <-s.ctx.Done():
+		return nil, status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+// CloseSend closes the program's stdin.
+func (s *stream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.inputs)
+	}
+	return nil
+}
+
+func (s *stream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *stream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *stream) Context() context.Context     { return s.ctx }
+
+func (s *stream) SendMsg(m any) error {
+	req, ok := m.(*compiler_service.ExecuteRequest)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	return s.Send(req)
+}
+
+func (s *stream) RecvMsg(m any) error {
+	resp, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	proto.Merge(msg, resp)
+	return nil
+}
+
+// run compiles and runs source in a fresh working directory, streaming its output as responses.
+func (s *stream) run(source string) {
+	defer func() {
+		close(s.responses)
+		s.cancel()
+	}()
+
+	dir, err := os.MkdirTemp(s.exec.cfg.WorkDir, "exec-")
+	if err != nil {
+		s.sendError(fmt.Sprintf("failed to create working directory: %v", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, s.exec.tc.file), []byte(source), 0o644); err != nil {
+		s.sendError(fmt.Sprintf("failed to write source file: %v", err))
+		return
+	}
+
+	// a wall timeout of 0 or less leaves the program running until the stream ends
+	ctx := s.ctx
+	if s.exec.cfg.WallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(s.ctx, s.exec.cfg.WallTimeout)
+		defer cancel()
+	}
+
+	s.send(&compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Status{Status: &compiler_service.Status{State: StateRunning}}})
+
+	if len(s.exec.tc.compile) > 0 {
+		code, err := s.execute(ctx, dir, s.exec.tc.compile, false)
+		if err != nil || code != 0 {
+			s.finish(ctx, code, err)
+			return
+		}
+	}
+
+	code, err := s.execute(ctx, dir, s.exec.tc.run, true)
+	s.finish(ctx, code, err)
+}
+
+// execute runs argv in dir under the configured limits, forwarding stdout and stderr.
+// stdin is only connected to the stream for the program itself, not for the compiler.
+func (s *stream) execute(ctx context.Context, dir string, argv []string, withStdin bool) (int, error) {
+	cmd := command(ctx, dir, argv, s.exec.cfg)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return -1, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return -1, err
+	}
+	var stdin io.WriteCloser
+	if withStdin {
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return -1, err
+		}
+	}
+
+	if err := startProcess(cmd, s.exec.cfg, s.exec.logger); err != nil {
+		return -1, err
+	}
+	s.exec.logger.Info("Started local execution", map[string]any{"session_id": s.sessionID, "language": s.exec.language, "pid": cmd.Process.Pid})
+
+	if stdin != nil {
+		go s.feedStdin(stdin)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.forward(stdout, func(text string) *compiler_service.ExecuteResponse {
+			return &compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Output{Output: &compiler_service.Output{OutputText: text}}}
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		s.forward(stderr, func(text string) *compiler_service.ExecuteResponse {
+			return &compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Error{Error: &compiler_service.Error{ErrorText: text}}}
+		})
+	}()
+	wg.Wait()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// feedStdin copies submitted input into the program until stdin is closed or the program exits.
+func (s *stream) feedStdin(stdin io.WriteCloser) {
+	defer stdin.Close()
+	for {
+		select {
+		case input, ok := <-s.inputs:
+			if !ok {
+				return
+			}
+			if _, err := stdin.Write(input); err != nil {
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *stream) forward(r io.Reader, wrap func(string) *compiler_service.ExecuteResponse) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.send(wrap(string(buf[:n])))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// finish reports how the program ended.
+func (s *stream) finish(ctx context.Context, code int, err error) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && s.ctx.Err() == nil:
+		s.sendError(fmt.Sprintf("execution timed out after %s", s.exec.cfg.WallTimeout))
+	case err != nil:
+		s.sendError(fmt.Sprintf("failed to run program: %v", err))
+	}
+	s.send(&compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Status{Status: &compiler_service.Status{State: fmt.Sprintf("%s%d", StateExitCode, code)}}})
+	s.send(&compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Status{Status: &compiler_service.Status{State: StateComplete}}})
+	s.exec.logger.Info("Local execution finished", map[string]any{"session_id": s.sessionID, "language": s.exec.language, "exit_code": code})
+}
+
+func (s *stream) sendError(text string) {
+	s.send(&compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Error{Error: &compiler_service.Error{ErrorText: text}}})
+}
+
+func (s *stream) send(resp *compiler_service.ExecuteResponse) {
+	resp.SessionId = s.sessionID
+	select {
+	case s.responses <- resp:
+	case <-s.ctx.Done():
+	}
+}
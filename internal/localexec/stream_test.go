@@ -0,0 +1,128 @@
+package localexec
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"github.com/sirupsen/logrus"
+)
+
+// transcript is what an execution stream sent back until it ended.
+type transcript struct {
+	stdout, stderr string
+	states         []string
+	err            error
+}
+
+func (tr transcript) exitCode() string {
+	for _, state := range tr.states {
+		if code, ok := strings.CutPrefix(state, StateExitCode); ok {
+			return code
+		}
+	}
+	return ""
+}
+
+func pythonExecutor(t *testing.T, wallTimeout time.Duration) *Executor {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	e, err := New("python", &config.LocalExec{WorkDir: t.TempDir(), WallTimeout: wallTimeout, CPUSeconds: 10}, &lgg.Logger{Logger: log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// run executes code, sending inputs and closing stdin unless keepOpen, and collects the responses.
+func run(t *testing.T, e *Executor, code string, keepOpen bool, inputs ...string) transcript {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := e.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []*compiler_service.ExecuteRequest{{SessionId: "s1", Payload: &compiler_service.ExecuteRequest_Code{Code: &compiler_service.Code{SourceCode: code}}}}
+	for _, input := range inputs {
+		requests = append(requests, &compiler_service.ExecuteRequest{SessionId: "s1", Payload: &compiler_service.ExecuteRequest_Input{Input: &compiler_service.Input{InputText: input}}})
+	}
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if !keepOpen {
+		stream.CloseSend()
+	}
+
+	var tr transcript
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				tr.err = err
+			}
+			return tr
+		}
+		switch payload := resp.Payload.(type) {
+		case *compiler_service.ExecuteResponse_Output:
+			tr.stdout += payload.Output.OutputText
+		case *compiler_service.ExecuteResponse_Error:
+			tr.stderr += payload.Error.ErrorText
+		case *compiler_service.ExecuteResponse_Status:
+			tr.states = append(tr.states, payload.Status.State)
+		}
+	}
+}
+
+func TestStream_OutputAndStderr(t *testing.T) {
+	e := pythonExecutor(t, 5*time.Second)
+	tr := run(t, e, "import sys\nprint('out')\nprint('err', file=sys.stderr)\nsys.exit(3)\n", false)
+	if tr.err != nil || tr.stdout != "out\n" || tr.stderr != "err\n" {
+		t.Fatalf("got %+v, want out on stdout and err on stderr", tr)
+	}
+	if tr.exitCode() != "3" || tr.states[0] != StateRunning || tr.states[len(tr.states)-1] != StateComplete {
+		t.Fatalf("got states %v, want running, exit code 3 and complete", tr.states)
+	}
+}
+
+func TestStream_Stdin(t *testing.T) {
+	e := pythonExecutor(t, 5*time.Second)
+
+	// inputs are lines, and closing the stream ends stdin
+	tr := run(t, e, "import sys\nlines = sys.stdin.read().splitlines()\nprint(len(lines), '+'.join(lines))\n", false, "1", "2\n")
+	if tr.err != nil || tr.stdout != "2 1+2\n" || tr.exitCode() != "0" {
+		t.Fatalf("got %+v, want both lines read up to EOF", tr)
+	}
+}
+
+func TestStream_WallTimeout(t *testing.T) {
+	e := pythonExecutor(t, 300*time.Millisecond)
+	start := time.Now()
+	tr := run(t, e, "print('started', flush=True)\ninput()\n", true)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the program ran for %s", elapsed)
+	}
+	if tr.stdout != "started\n" || !strings.Contains(tr.stderr, "timed out") || tr.states[len(tr.states)-1] != StateComplete {
+		t.Fatalf("got %+v, want the program killed after its output", tr)
+	}
+}
+
+func TestStream_NoWallTimeout(t *testing.T) {
+	e := pythonExecutor(t, 0)
+	tr := run(t, e, "import time\ntime.sleep(0.2)\nprint('done')\n", false)
+	if tr.err != nil || tr.stdout != "done\n" || tr.stderr != "" || tr.exitCode() != "0" {
+		t.Fatalf("got %+v, want the program to run to completion", tr)
+	}
+}
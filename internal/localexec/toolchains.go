@@ -0,0 +1,38 @@
+package localexec
+
+// toolchain describes how to build and run a single source file of a language.
+// Commands are run inside the execution's working directory.
+type toolchain struct {
+	file    string
+	compile []string
+	run     []string
+}
+
+var toolchains = map[string]toolchain{
+	"python": {
+		file: "main.py",
+		run:  []string{"python3", "-u", "main.py"},
+	},
+	"javascript": {
+		file: "main.js",
+		run:  []string{"node", "main.js"},
+	},
+	"cpp": {
+		file:    "main.cpp",
+		compile: []string{"g++", "-O2", "-std=c++17", "-o", "main", "main.cpp"},
+		run:     []string{"./main"},
+	},
+	"java": {
+		file: "Main.java",
+		run:  []string{"java", "Main.java"},
+	},
+	"go": {
+		file:    "main.go",
+		compile: []string{"go", "build", "-o", "main", "main.go"},
+		run:     []string{"./main"},
+	},
+	"php": {
+		file: "main.php",
+		run:  []string{"php", "main.php"},
+	},
+}
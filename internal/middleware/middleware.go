@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	logger "github.com/ruziba3vich/prodonik_lgger"
@@ -10,14 +12,16 @@ import (
 )
 
 type MidWare struct {
-	logger  *logger.Logger
-	limiter *limiter.TokenBucketLimiter
+	logger     *logger.Logger
+	limiter    *limiter.TokenBucketLimiter
+	adminToken string
 }
 
-func NewMidWare(logger *logger.Logger, limiter *limiter.TokenBucketLimiter) *MidWare {
+func NewMidWare(logger *logger.Logger, limiter *limiter.TokenBucketLimiter, adminToken string) *MidWare {
 	return &MidWare{
-		logger:  logger,
-		limiter: limiter,
+		logger:     logger,
+		limiter:    limiter,
+		adminToken: adminToken,
 	}
 }
 
@@ -57,7 +61,7 @@ func (m *MidWare) RateLimit() gin.HandlerFunc {
 func (m *MidWare) CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
 		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -70,3 +74,26 @@ func (m *MidWare) CORS() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminAuth only lets through requests carrying "Authorization: Bearer <ADMIN_TOKEN>".
+// The admin API is disabled entirely when no token is configured.
+func (m *MidWare) AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.adminToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			c.Abort()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(m.adminToken)) != 1 {
+			m.logger.Warn("AdminAuth: Invalid admin token", map[string]any{"ip": c.ClientIP(), "path": c.Request.URL.Path})
+
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Job is an execution submitted to run in the background. It is stored as JSON while it waits
+// in the queue, runs and, once finished, keeps its Output or the Error that kept it from running.
+type Job struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Language   string     `json:"language"`
+	Version    string     `json:"version,omitempty"`
+	Code       string     `json:"code"`
+	Stdin      string     `json:"stdin,omitempty"`
+	Attempts   int        `json:"attempts"`
+	Output     *JobOutput `json:"output,omitempty"`
+	Error      *JobError  `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Receipt string `json:"-"` // identifies the delivery of the job to a worker, set by the queue
+}
+
+// JobOutput is what a finished job's program wrote and how it ended.
+type JobOutput struct {
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	ExitCode    *int   `json:"exit_code,omitempty"`
+	Reason      string `json:"reason"`
+	WallTimeMs  int64  `json:"wall_time_ms"`
+	TTFBMs      *int64 `json:"ttfb_ms,omitempty"`
+	OutputBytes int64  `json:"output_bytes"`
+	OutputLines int64  `json:"output_lines"`
+	LimitName   string `json:"limit_name,omitempty"`
+	LimitMax    int64  `json:"limit_max,omitempty"`
+	ErrorCode   string `json:"error_code,omitempty"`
+	ErrorText   string `json:"error_text,omitempty"`
+}
+
+// JobError is why a job could not run.
+type JobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
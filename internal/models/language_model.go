@@ -1,6 +1,20 @@
 package models
 
+import "time"
+
 type Language struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"uniqueIndex;not null"`
+	ID              uint   `gorm:"primaryKey"`
+	Name            string `gorm:"uniqueIndex:idx_languages_name_version;not null"`
+	DisplayName     string
+	Version         string `gorm:"uniqueIndex:idx_languages_name_version;not null;default:''"`
+	IsDefault       bool   `gorm:"not null;default:false"`
+	ExecutorAddress string `gorm:"not null;default:''"` // comma separated list of executor backends
+	LoadBalancing   string `gorm:"not null;default:''"`
+	Enabled         bool   `gorm:"not null;default:false"`
+	TLSEnabled      bool   `gorm:"column:tls_enabled;not null;default:false"`
+	TLSCAFile       string `gorm:"column:tls_ca_file;not null;default:''"`
+	TLSCertFile     string `gorm:"column:tls_cert_file;not null;default:''"`
+	TLSKeyFile      string `gorm:"column:tls_key_file;not null;default:''"`
+	TLSServerName   string `gorm:"column:tls_server_name;not null;default:''"`
+	UpdatedAt       time.Time
 }
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// pooledConn is a connection to an executor address shared by the languages using it. It counts its
+// active streams so that, once a reload removed the address, it is closed when the executions still
+// running on it have ended rather than cutting them short.
+type pooledConn struct {
+	*grpc.ClientConn
+
+	mu      sync.Mutex
+	streams int
+	retired bool
+}
+
+func dial(address string, creds credentials.TransportCredentials) (*pooledConn, error) {
+	c := &pooledConn{}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds), grpc.WithStreamInterceptor(c.track))
+	if err != nil {
+		return nil, err
+	}
+	c.ClientConn = conn
+	return c, nil
+}
+
+// track counts the stream it opens as active until it fails, ends or its context is done.
+func (c *pooledConn) track(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	c.mu.Lock()
+	c.streams++
+	c.mu.Unlock()
+
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	var once sync.Once
+	done := func() { once.Do(c.release) }
+	return &trackedStream{ClientStream: stream, done: done, stop: context.AfterFunc(ctx, done)}, nil
+}
+
+func (c *pooledConn) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams--
+	if c.retired && c.streams == 0 {
+		c.ClientConn.Close()
+	}
+}
+
+// retire closes the connection as soon as it has no active streams.
+func (c *pooledConn) retire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retired = true
+	if c.streams == 0 {
+		c.ClientConn.Close()
+	}
+}
+
+// trackedStream reports the end of a stream, which RecvMsg returning an error marks.
+type trackedStream struct {
+	grpc.ClientStream
+	done func()
+	stop func() bool
+}
+
+func (s *trackedStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.stop()
+		s.done()
+	}
+	return err
+}
@@ -0,0 +1,345 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/localexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+)
+
+// Registry keeps the pool of gRPC executor clients of every enabled language stored in the languages table
+// and rebuilds it whenever the rows change. Connections are shared between languages using the same address.
+type Registry struct {
+	logger   *lgg.Logger
+	storage  repos.LanguageStorage
+	interval time.Duration
+	localCfg *config.LocalExec
+
+	mu        sync.RWMutex
+	executors []repos.Executor
+	conns     map[string]*pooledConn
+	signature string
+	listeners []func([]repos.Executor)
+
+	reloadMu sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRegistry seeds the languages table from cfg.Executors when it is empty and loads the executors from it.
+func NewRegistry(cfg *config.Config, logger *lgg.Logger, storage repos.LanguageStorage) (*Registry, error) {
+	r := &Registry{
+		logger:   logger,
+		storage:  storage,
+		interval: cfg.RegistryReloadInterval,
+		localCfg: cfg.LocalExecCfg,
+		conns:    make(map[string]*pooledConn),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	ctx := context.Background()
+	if err := storage.Seed(ctx, seedLanguages(cfg.Executors, cfg.ExecutorTLS)); err != nil {
+		logger.Error("Failed to seed languages table", map[string]any{"error": err})
+		return nil, err
+	}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Executors returns a snapshot of the currently enabled executors ordered by language.
+func (r *Registry) Executors() []repos.Executor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.executors)
+}
+
+// OnChange registers fn to be called with the new executor set after every reload that changed it.
+func (r *Registry) OnChange(fn func([]repos.Executor)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload re-reads the languages table, dials new executor backends and notifies subscribers if anything changed.
+// Rows whose executors cannot be built are logged and skipped, and retried by the next reload.
+func (r *Registry) Reload(ctx context.Context) error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	rows, err := r.storage.List(ctx)
+	if err != nil {
+		r.logger.Error("Failed to load languages", map[string]any{"error": err})
+		return err
+	}
+
+	signature := fingerprint(rows)
+	r.mu.RLock()
+	current, unchanged := r.conns, r.executors != nil && signature == r.signature
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	executors := make([]repos.Executor, 0, len(rows))
+	conns := make(map[string]*pooledConn, len(rows))
+	skipped := 0
+	for _, row := range rows {
+		if !row.Enabled || len(splitAddresses(row.ExecutorAddress)) == 0 {
+			continue
+		}
+		executor, err := r.build(&row, current, conns)
+		if err != nil {
+			// one bad row must not keep the others from being applied
+			r.logger.Error("Skipping invalid language executor", map[string]any{"language": row.Name, "version": row.Version, "error": err})
+			skipped++
+			continue
+		}
+		executors = append(executors, executor)
+	}
+	if skipped > 0 {
+		// retry the skipped rows on the next poll, their files may appear in the meantime
+		signature = ""
+	}
+
+	r.mu.Lock()
+	stale := make([]*pooledConn, 0)
+	for key, conn := range r.conns {
+		if _, ok := conns[key]; !ok {
+			stale = append(stale, conn)
+		}
+	}
+	r.executors = executors
+	r.conns = conns
+	r.signature = signature
+	listeners := slices.Clone(r.listeners)
+	r.mu.Unlock()
+
+	r.logger.Info("Executor registry reloaded", map[string]any{"languages": len(executors), "skipped": skipped, "closing": len(stale)})
+
+	// executions still running on removed addresses keep their connection until they end
+	for _, conn := range stale {
+		conn.retire()
+	}
+	for _, fn := range listeners {
+		fn(slices.Clone(executors))
+	}
+	return nil
+}
+
+// build creates the executor of row, reusing the connections of current and adding the ones
+// it uses to conns. The connections it dialed are closed again when it fails.
+func (r *Registry) build(row *models.Language, current, conns map[string]*pooledConn) (repos.Executor, error) {
+	addresses := splitAddresses(row.ExecutorAddress)
+	backends := make([]repos.Backend, 0, len(addresses))
+	dialed := make(map[string]*pooledConn)
+	fail := func(err error) (repos.Executor, error) {
+		for key, conn := range dialed {
+			delete(conns, key)
+			conn.Close()
+		}
+		return repos.Executor{}, err
+	}
+
+	for _, address := range addresses {
+		if strings.HasPrefix(address, localexec.Scheme) {
+			client, err := localexec.New(row.Name, r.localCfg, r.logger)
+			if err != nil {
+				return fail(err)
+			}
+			backends = append(backends, repos.Backend{Address: address, Client: client})
+			continue
+		}
+
+		key := connKey(address, row)
+		conn, ok := conns[key]
+		if !ok {
+			conn, ok = current[key]
+		}
+		if !ok {
+			creds, err := transportCredentials(row, address, r.logger)
+			if err != nil {
+				return fail(fmt.Errorf("invalid TLS settings: %w", err))
+			}
+			conn, err = dial(address, creds)
+			if err != nil {
+				return fail(fmt.Errorf("connect to %s: %w", address, err))
+			}
+			dialed[key] = conn
+			r.logger.Info("Connected to gRPC service", map[string]any{"language": row.Name, "address": address, "tls": row.TLSEnabled})
+		}
+		conns[key] = conn
+
+		backends = append(backends, repos.Backend{
+			Address: address,
+			Client:  compiler_service.NewCodeExecutorClient(conn),
+			Health:  grpc_health_v1.NewHealthClient(conn),
+		})
+	}
+
+	return repos.Executor{
+		Language:      strings.ToLower(row.Name),
+		DisplayName:   row.DisplayName,
+		Version:       row.Version,
+		Default:       row.IsDefault,
+		LoadBalancing: row.LoadBalancing,
+		Backends:      backends,
+	}, nil
+}
+
+// Check returns why the executors of row could not be built: an unknown load balancing strategy,
+// a malformed address, a language the local executor does not support or unusable TLS files.
+func (r *Registry) Check(row *models.Language) error {
+	switch row.LoadBalancing {
+	case "", repos.RoundRobin, repos.LeastActive:
+	default:
+		return fmt.Errorf("unknown load balancing strategy %q", row.LoadBalancing)
+	}
+
+	for _, address := range splitAddresses(row.ExecutorAddress) {
+		if strings.HasPrefix(address, localexec.Scheme) {
+			if _, err := localexec.New(row.Name, r.localCfg, r.logger); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := checkAddress(address); err != nil {
+			return err
+		}
+		if _, err := transportCredentials(row, address, r.logger); err != nil {
+			return fmt.Errorf("invalid TLS settings: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start polls the languages table in the background so that edits made outside the admin API are picked up.
+func (r *Registry) Start() {
+	go func() {
+		defer close(r.done)
+		if r.interval <= 0 {
+			<-r.stop
+			return
+		}
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+				_ = r.Reload(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close stops the background polling and tears down every gRPC connection owned by the registry.
+func (r *Registry) Close() error {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, conn := range r.conns {
+		errs = append(errs, conn.Close())
+	}
+	r.conns = nil
+	return errors.Join(errs...)
+}
+
+// fingerprint summarizes the columns of rows that affect the executors, so that polling
+// only rebuilds the registry when one of them changed.
+func fingerprint(rows []models.Language) string {
+	var sb strings.Builder
+	for _, row := range rows {
+		fmt.Fprintf(&sb, "%q %q %q %t %q %q %t %s\n",
+			row.Name, row.DisplayName, row.Version, row.IsDefault, row.ExecutorAddress, row.LoadBalancing, row.Enabled, connKey("", &row))
+	}
+	return sb.String()
+}
+
+// connKey identifies a connection by address and transport settings, so that
+// languages only share a connection when they reach the backend the same way.
+func connKey(address string, row *models.Language) string {
+	if !row.TLSEnabled {
+		return address
+	}
+	return fmt.Sprintf("%s tls(%q %q %q %q)", address, row.TLSCAFile, row.TLSCertFile, row.TLSKeyFile, row.TLSServerName)
+}
+
+// splitAddresses parses a comma separated list of executor addresses, dropping blanks and duplicates.
+func splitAddresses(value string) []string {
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" && !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// checkAddress accepts "host:port" and gRPC targets with a registered scheme, such as "dns:///host:port".
+func checkAddress(address string) error {
+	if scheme, _, ok := strings.Cut(address, "://"); ok {
+		if resolver.Get(scheme) == nil {
+			return fmt.Errorf("executor address %q has an unknown scheme", address)
+		}
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("executor address %q is not host:port", address)
+	}
+	if n, err := strconv.Atoi(port); host == "" || err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("executor address %q is not host:port", address)
+	}
+	return nil
+}
+
+// seedLanguages turns the EXECUTORS entries into rows. Keys may carry a version as "name@version".
+func seedLanguages(executors map[string]string, tlsCfg *config.TLS) []models.Language {
+	languages := make([]models.Language, 0, len(executors))
+	for key, address := range executors {
+		name, version, _ := strings.Cut(key, "@")
+		languages = append(languages, models.Language{
+			Name:            strings.ToLower(name),
+			DisplayName:     name,
+			Version:         version,
+			ExecutorAddress: strings.ReplaceAll(address, "|", ","),
+			Enabled:         true,
+			TLSEnabled:      tlsCfg.Enabled,
+			TLSCAFile:       tlsCfg.CAFile,
+			TLSCertFile:     tlsCfg.CertFile,
+			TLSKeyFile:      tlsCfg.KeyFile,
+			TLSServerName:   tlsCfg.ServerName,
+			UpdatedAt:       time.Now(),
+		})
+	}
+	slices.SortFunc(languages, func(a, b models.Language) int {
+		return strings.Compare(a.Name+"@"+a.Version, b.Name+"@"+b.Version)
+	})
+	return languages
+}
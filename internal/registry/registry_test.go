@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func newRegistry(t *testing.T, rows ...models.Language) (*Registry, repos.LanguageStorage) {
+	t.Helper()
+	storage := testutil.Storage(t)
+	for _, row := range rows {
+		if err := storage.Upsert(context.Background(), &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := testutil.Config()
+	cfg.Executors = map[string]string{}
+	r, err := NewRegistry(cfg, testutil.Logger(), storage)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	r.Start()
+	t.Cleanup(func() { r.Close() })
+	return r, storage
+}
+
+func languages(r *Registry) string {
+	var names []string
+	for _, e := range r.Executors() {
+		names = append(names, e.Language+"@"+e.Backends[0].Address)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestReload_SkipsInvalidRows(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	r, storage := newRegistry(t,
+		models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001", Enabled: true},
+		models.Language{Name: "java", ExecutorAddress: "127.0.0.1:7002", Enabled: true, TLSEnabled: true, TLSCAFile: missing},
+		models.Language{Name: "cobol", ExecutorAddress: "local://", Enabled: true},
+	)
+
+	// the bad rows do not keep the registry from starting
+	if got := languages(r); got != "python@127.0.0.1:7001" {
+		t.Fatalf("got executors %q, want only the valid row", got)
+	}
+
+	// nor from applying later edits
+	ctx := context.Background()
+	storage.Upsert(ctx, &models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7003", Enabled: true})
+	storage.Upsert(ctx, &models.Language{Name: "go", ExecutorAddress: "127.0.0.1:7004", Enabled: true})
+	if err := r.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := languages(r); got != "go@127.0.0.1:7004 python@127.0.0.1:7003" {
+		t.Fatalf("got executors %q after the edit", got)
+	}
+}
+
+func TestReload_RetriesSkippedRows(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCA(t, dir)
+	pending := filepath.Join(dir, "later.pem")
+	r, _ := newRegistry(t, models.Language{Name: "java", ExecutorAddress: "127.0.0.1:7002", Enabled: true, TLSEnabled: true, TLSCAFile: pending})
+	if got := languages(r); got != "" {
+		t.Fatalf("got executors %q, want the row skipped", got)
+	}
+
+	// the CA bundle appearing is picked up although the row did not change
+	copyFile(t, ca, pending)
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := languages(r); got != "java@127.0.0.1:7002" {
+		t.Fatalf("got executors %q, want the row applied once its files exist", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r, _ := newRegistry(t)
+	ca := writeCA(t, t.TempDir())
+
+	cases := []struct {
+		row models.Language
+		err string
+	}{
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001"}, ""},
+		{models.Language{Name: "python", ExecutorAddress: "executor:7001, dns:///executor:7002", LoadBalancing: repos.LeastActive}, ""},
+		{models.Language{Name: "python", ExecutorAddress: "local://"}, ""},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001", TLSEnabled: true, TLSCAFile: ca}, ""},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001", LoadBalancing: "random"}, "load balancing"},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1"}, "host:port"},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:http"}, "host:port"},
+		{models.Language{Name: "python", ExecutorAddress: "nope://executor"}, "unknown scheme"},
+		{models.Language{Name: "cobol", ExecutorAddress: "local://"}, "does not support"},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001", TLSEnabled: true, TLSCAFile: "/missing/ca.pem"}, "TLS"},
+		{models.Language{Name: "python", ExecutorAddress: "127.0.0.1:7001", TLSEnabled: true, TLSCertFile: ca}, "TLS"},
+	}
+	for _, tc := range cases {
+		err := r.Check(&tc.row)
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Check(%s %q lb=%q): got %v, want error containing %q", tc.row.Name, tc.row.ExecutorAddress, tc.row.LoadBalancing, err, tc.err)
+		}
+	}
+}
+
+func TestReload_ClosesRemovedConnectionsOnceIdle(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	compiler_service.RegisterCodeExecutorServer(server, exec)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	address := listener.Addr().String()
+	r, storage := newRegistry(t, models.Language{Name: "python", ExecutorAddress: address, Enabled: true})
+	conn := r.conns[address]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := r.Executors()[0].Backends[0].Client.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&compiler_service.ExecuteRequest{Payload: &compiler_service.ExecuteRequest_Code{Code: &compiler_service.Code{Language: "python", SourceCode: "print(1)"}}})
+	exec.WaitOpen(1, 2*time.Second)
+
+	// removing the language leaves the running execution alone
+	if err := storage.Delete(context.Background(), "python", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := languages(r); got != "" {
+		t.Fatalf("got executors %q, want none", got)
+	}
+	if state := conn.GetState(); state == connectivity.Shutdown {
+		t.Fatal("connection closed under a running execution")
+	}
+
+	// and its connection is closed once the execution ended
+	cancel()
+	waitCtx, stop := context.WithTimeout(context.Background(), 2*time.Second)
+	defer stop()
+	for state := conn.GetState(); state != connectivity.Shutdown; state = conn.GetState() {
+		if !conn.WaitForStateChange(waitCtx, state) {
+			t.Fatalf("connection still %s after the execution ended", state)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials returns the gRPC credentials configured for the language row to reach
+// the executor at address. The executor's certificate must be valid for row.TLSServerName or,
+// when it is empty, for the host of address, IP addresses included.
+func transportCredentials(row *models.Language, address string, logger *lgg.Logger) (credentials.TransportCredentials, error) {
+	if !row.TLSEnabled {
+		return insecure.NewCredentials(), nil
+	}
+	if (row.TLSCertFile == "") != (row.TLSKeyFile == "") {
+		return nil, errors.New("tls_cert_file and tls_key_file must be set together")
+	}
+
+	files := &certFiles{
+		language: row.Name,
+		caFile:   row.TLSCAFile,
+		certFile: row.TLSCertFile,
+		keyFile:  row.TLSKeyFile,
+		logger:   logger,
+	}
+	// fail fast on unreadable files instead of on the first handshake
+	if err := files.load(); err != nil {
+		return nil, err
+	}
+
+	serverName := row.TLSServerName
+	if serverName == "" {
+		serverName = dialHost(address)
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if files.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return files.clientCertificate()
+		}
+	}
+	if files.caFile != "" {
+		// the standard verification can only use a fixed RootCAs pool, so it is replaced
+		// by an equivalent check against the CA bundle currently on disk
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return files.verifyConnection(cs, serverName)
+		}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// certFiles serves the CA bundle and client key pair of an executor from disk
+// and re-reads them whenever their modification time changes.
+type certFiles struct {
+	language                  string
+	caFile, certFile, keyFile string
+	logger                    *lgg.Logger
+
+	mu          sync.Mutex
+	caModTime   time.Time
+	certModTime time.Time
+	roots       *x509.CertPool
+	cert        *tls.Certificate
+}
+
+// load re-reads every file whose modification time changed since the previous load.
+// On a failed reload the previously loaded material stays in use.
+func (f *certFiles) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	if f.caFile != "" {
+		if modTime, err := modTime(f.caFile); err != nil {
+			errs = append(errs, err)
+		} else if !modTime.Equal(f.caModTime) {
+			pem, err := os.ReadFile(f.caFile)
+			pool := x509.NewCertPool()
+			if err == nil && !pool.AppendCertsFromPEM(pem) {
+				err = fmt.Errorf("no certificates found in %s", f.caFile)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				f.roots, f.caModTime = pool, modTime
+				f.logger.Info("Loaded executor CA bundle", map[string]any{"language": f.language, "file": f.caFile})
+			}
+		}
+	}
+
+	if f.certFile != "" {
+		certModTime, err := modTime(f.certFile)
+		if err == nil {
+			var keyModTime time.Time
+			keyModTime, err = modTime(f.keyFile)
+			if keyModTime.After(certModTime) {
+				certModTime = keyModTime
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else if !certModTime.Equal(f.certModTime) {
+			cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				f.cert, f.certModTime = &cert, certModTime
+				f.logger.Info("Loaded executor client certificate", map[string]any{"language": f.language, "file": f.certFile})
+			}
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err != nil && (f.roots != nil || f.cert != nil) {
+		f.logger.Warn("Failed to reload executor TLS files, keeping the previous ones", map[string]any{"language": f.language, "error": err})
+	}
+	return err
+}
+
+func (f *certFiles) clientCertificate() (*tls.Certificate, error) {
+	_ = f.load()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cert == nil {
+		return nil, fmt.Errorf("client certificate %s is not loaded", f.certFile)
+	}
+	return f.cert, nil
+}
+
+// verifyConnection checks the server chain against the current CA bundle and serverName.
+// No SNI is sent to executors addressed by IP, so cs.ServerName cannot be relied on.
+func (f *certFiles) verifyConnection(cs tls.ConnectionState, serverName string) error {
+	_ = f.load()
+
+	f.mu.Lock()
+	roots := f.roots
+	f.mu.Unlock()
+	if roots == nil {
+		return fmt.Errorf("CA bundle %s is not loaded", f.caFile)
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("executor presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// dialHost returns the host part of an executor address such as "10.0.0.5:702" or "dns:///executor:702".
+func dialHost(address string) string {
+	if _, target, ok := strings.Cut(address, "://"); ok {
+		address = target[strings.LastIndex(target, "/")+1:]
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
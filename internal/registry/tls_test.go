@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+)
+
+// tlsExecutor is a TLS endpoint standing in for an executor, whose certificate and client
+// certificate requirements can be changed between handshakes.
+type tlsExecutor struct {
+	address  string
+	config   chan *tls.Config
+	peers    chan string // common name of each verified client certificate, "" if none
+	failures chan error
+}
+
+func newTLSExecutor(t *testing.T, cfg *tls.Config) *tlsExecutor {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	e := &tlsExecutor{
+		address:  listener.Addr().String(),
+		config:   make(chan *tls.Config, 1),
+		peers:    make(chan string, 16),
+		failures: make(chan error, 16),
+	}
+	e.config <- cfg
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			cfg := <-e.config
+			e.config <- cfg
+			// gRPC clients insist on negotiating HTTP/2
+			cfg = cfg.Clone()
+			cfg.NextProtos = []string{"h2"}
+			server := tls.Server(conn, cfg)
+			if err := server.Handshake(); err != nil {
+				e.failures <- err
+			} else if certs := server.ConnectionState().PeerCertificates; len(certs) > 0 {
+				e.peers <- certs[0].Subject.CommonName
+			} else {
+				e.peers <- ""
+			}
+			// read so that client certificate errors sent after a TLS 1.3 handshake reach the client
+			server.SetReadDeadline(time.Now().Add(time.Second))
+			server.Read(make([]byte, 1))
+			server.Close()
+		}
+	}()
+	return e
+}
+
+func (e *tlsExecutor) setConfig(cfg *tls.Config) {
+	<-e.config
+	e.config <- cfg
+}
+
+// handshake connects to e with the credentials of row and returns the client handshake error.
+func (e *tlsExecutor) handshake(t *testing.T, row *models.Language) error {
+	t.Helper()
+	creds, err := transportCredentials(row, e.address, testutil.Logger())
+	if err != nil {
+		t.Fatalf("transportCredentials: %v", err)
+	}
+	conn, err := net.Dial("tcp", e.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	secure, _, err := creds.ClientHandshake(ctx, e.address, conn)
+	if err == nil {
+		defer secure.Close()
+	}
+	return err
+}
+
+// serverResult returns the common name of the client certificate verified by e, or its handshake error.
+func (e *tlsExecutor) serverResult(t *testing.T) (string, error) {
+	t.Helper()
+	select {
+	case cn := <-e.peers:
+		return cn, nil
+	case err := <-e.failures:
+		return "", err
+	case <-time.After(2 * time.Second):
+		t.Fatal("executor did not finish the handshake")
+		return "", nil
+	}
+}
+
+func TestTLS_VerifiesExecutorName(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, "executors")
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.pem)
+
+	cases := []struct {
+		name       string
+		hosts      []string
+		serverName string
+		ok         bool
+	}{
+		{"certificate for another host", []string{"evil.example"}, "", false},
+		{"certificate for the executor IP", []string{"127.0.0.1"}, "", true},
+		{"certificate for the configured name", []string{"executor.internal"}, "executor.internal", true},
+		{"configured name not in the certificate", []string{"127.0.0.1"}, "executor.internal", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			executor := newTLSExecutor(t, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, "executor", tc.hosts...)}})
+			err := executor.handshake(t, &models.Language{Name: "python", TLSEnabled: true, TLSCAFile: caFile, TLSServerName: tc.serverName})
+			if tc.ok && err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			if !tc.ok && (err == nil || !strings.Contains(err.Error(), "certificate")) {
+				t.Fatalf("got %v, want the certificate rejected", err)
+			}
+		})
+	}
+}
+
+func TestTLS_RejectsUntrustedCA(t *testing.T) {
+	dir := t.TempDir()
+	trusted, other := newCA(t, "trusted"), newCA(t, "other")
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, trusted.pem)
+
+	executor := newTLSExecutor(t, &tls.Config{Certificates: []tls.Certificate{other.issue(t, "executor", "127.0.0.1")}})
+	if err := executor.handshake(t, &models.Language{Name: "python", TLSEnabled: true, TLSCAFile: caFile}); err == nil {
+		t.Fatal("handshake succeeded with a certificate of an untrusted CA")
+	}
+}
+
+func TestTLS_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, "executors")
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeFile(t, caFile, ca.pem)
+	writeKeyPair(t, ca.issue(t, "gateway"), certFile, keyFile)
+
+	executor := newTLSExecutor(t, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "executor", "127.0.0.1")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool(),
+	})
+
+	executor.handshake(t, &models.Language{Name: "python", TLSEnabled: true, TLSCAFile: caFile})
+	if _, err := executor.serverResult(t); err == nil {
+		t.Fatal("executor accepted a client without certificate")
+	}
+
+	if err := executor.handshake(t, &models.Language{Name: "python", TLSEnabled: true, TLSCAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile}); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if cn, err := executor.serverResult(t); err != nil || cn != "gateway" {
+		t.Fatalf("executor got client %q (%v), want the gateway certificate", cn, err)
+	}
+}
+
+func TestTLS_ReloadsFilesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newCA(t, "old"), newCA(t, "new")
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeFile(t, caFile, oldCA.pem)
+	writeKeyPair(t, oldCA.issue(t, "gateway-1"), certFile, keyFile)
+
+	pool := oldCA.pool()
+	pool.AddCert(newCA.cert)
+	executor := newTLSExecutor(t, &tls.Config{
+		Certificates: []tls.Certificate{oldCA.issue(t, "executor", "127.0.0.1")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	row := &models.Language{Name: "python", TLSEnabled: true, TLSCAFile: caFile, TLSCertFile: certFile, TLSKeyFile: keyFile}
+	creds, err := transportCredentials(row, executor.address, testutil.Logger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshake := func() error {
+		conn, err := net.Dial("tcp", executor.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, _, err = creds.ClientHandshake(context.Background(), executor.address, conn)
+		return err
+	}
+
+	if err := handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if cn, _ := executor.serverResult(t); cn != "gateway-1" {
+		t.Fatalf("executor got client %q, want gateway-1", cn)
+	}
+
+	// the executor moves to a certificate of the new CA, which the gateway does not trust yet
+	executor.setConfig(&tls.Config{
+		Certificates: []tls.Certificate{newCA.issue(t, "executor", "127.0.0.1")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	if err := handshake(); err == nil {
+		t.Fatal("handshake succeeded with a certificate of a CA not yet trusted")
+	}
+	executor.serverResult(t)
+
+	// rotating the files on disk is picked up by the same credentials
+	writeFile(t, caFile, newCA.pem)
+	writeKeyPair(t, newCA.issue(t, "gateway-2"), certFile, keyFile)
+	if err := handshake(); err != nil {
+		t.Fatalf("handshake failed after rotating the files: %v", err)
+	}
+	if cn, _ := executor.serverResult(t); cn != "gateway-2" {
+		t.Fatalf("executor got client %q, want the rotated gateway-2 certificate", cn)
+	}
+}
@@ -1,21 +1,69 @@
 package repos
 
-import "github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+import (
+	"context"
+	"errors"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
 
 type (
-	Python interface {
-		compiler_service.CodeExecutorClient
+	// Executor describes an enabled language version together with the pool of executor backends serving it.
+	Executor struct {
+		Language      string
+		DisplayName   string
+		Version       string
+		Default       bool
+		LoadBalancing string
+		Backends      []Backend
+	}
+
+	// Backend is a single executor service instance.
+	Backend struct {
+		Address string
+		Client  compiler_service.CodeExecutorClient
+		Health  grpc_health_v1.HealthClient
 	}
 
-	Java interface {
-		compiler_service.CodeExecutorClient
+	// ExecutorRegistry keeps the set of language executors and notifies subscribers when it changes.
+	ExecutorRegistry interface {
+		Executors() []Executor
+		OnChange(fn func([]Executor))
+		Reload(ctx context.Context) error
+		// Check returns why the executors of language could not be built, without storing it.
+		Check(language *models.Language) error
 	}
 
-	Cpp interface {
-		compiler_service.CodeExecutorClient
+	// JobQueue stores background jobs and hands them out to the workers of every gateway sharing it.
+	// A job handed out by Next stays pending until it is acknowledged with Done, so that the jobs
+	// of a gateway that stopped while running them are handed out again. Touch tells the other
+	// gateways that a pending job is still running, so that they do not take it over.
+	JobQueue interface {
+		Push(ctx context.Context, job *models.Job) error
+		Get(ctx context.Context, id string) (*models.Job, error)
+		Save(ctx context.Context, job *models.Job) error
+		Next(ctx context.Context) (*models.Job, error)
+		Touch(ctx context.Context, job *models.Job) error
+		Done(ctx context.Context, job *models.Job) error
 	}
 
-	Js interface {
-		compiler_service.CodeExecutorClient
+	// LanguageStorage persists language executor settings.
+	LanguageStorage interface {
+		List(ctx context.Context) ([]models.Language, error)
+		Get(ctx context.Context, name, version string) (*models.Language, error)
+		Upsert(ctx context.Context, language *models.Language) error
+		Delete(ctx context.Context, name, version string) error
+		Seed(ctx context.Context, languages []models.Language) error
 	}
 )
+
+// Load balancing strategies of an executor pool. An empty strategy means RoundRobin.
+const (
+	RoundRobin  = "round_robin"
+	LeastActive = "least_active"
+)
+
+// ErrJobNotFound is returned by JobQueue.Get for jobs that do not exist or have expired.
+var ErrJobNotFound = errors.New("job not found")
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a per-backend circuit breaker. It opens after threshold consecutive failures and,
+// once openTimeout has elapsed, turns half-open: a single trial execution is let through while
+// every other one is still rejected. A success closes it again, a failure reopens it and restarts
+// the timeout. A trial that never reports back is replaced by another one after openTimeout.
+type breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	failures    int
+	open        bool
+	openedAt    time.Time
+	trialAt     time.Time // when the trial of the half-open breaker was let through, zero if none
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// allow reports whether a new execution may be sent to the backend, without claiming the trial
+// of a half-open breaker.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.allowLocked()
+}
+
+func (b *breaker) allowLocked() bool {
+	if !b.open {
+		return true
+	}
+	return time.Since(b.openedAt) >= b.openTimeout && (b.trialAt.IsZero() || time.Since(b.trialAt) >= b.openTimeout)
+}
+
+// acquire reports whether an execution may be sent to the backend and, if the breaker is
+// half-open, claims its single trial.
+func (b *breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.allowLocked() {
+		return false
+	}
+	if b.open {
+		b.trialAt = time.Now()
+	}
+	return true
+}
+
+// isOpen reports whether the breaker is currently rejecting executions.
+func (b *breaker) isOpen() bool {
+	return !b.allow()
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.open = false
+	b.trialAt = time.Time{}
+}
+
+// failure records a failed call and reports whether it tripped the breaker open.
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		tripped := !b.open
+		b.open = true
+		b.openedAt = time.Now()
+		b.trialAt = time.Time{}
+		return tripped
+	}
+	return false
+}
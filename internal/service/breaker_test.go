@@ -0,0 +1,88 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b := newBreaker(2, time.Hour)
+
+	if b.failure() || !b.acquire() {
+		t.Fatal("breaker opened after 1 of 2 failures")
+	}
+	if !b.failure() {
+		t.Fatal("breaker did not trip on the 2nd failure")
+	}
+	if b.allow() || b.acquire() {
+		t.Fatal("open breaker let an execution through")
+	}
+
+	b.success()
+	if !b.acquire() || !b.acquire() {
+		t.Fatal("closed breaker rejected executions")
+	}
+}
+
+func TestBreaker_HalfOpenAdmitsSingleTrial(t *testing.T) {
+	b := newBreaker(1, 20*time.Millisecond)
+	b.failure()
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("breaker did not turn half-open after the timeout")
+	}
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.acquire() {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := admitted.Load(); n != 1 {
+		t.Fatalf("half-open breaker admitted %d executions, want a single trial", n)
+	}
+	if b.allow() {
+		t.Fatal("breaker reports executions allowed while its trial is running")
+	}
+
+	// a failed trial reopens it for another timeout
+	if b.failure() {
+		t.Fatal("failure of the trial reported as a new trip")
+	}
+	if b.acquire() {
+		t.Fatal("breaker let an execution through right after a failed trial")
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// a successful one closes it
+	if !b.acquire() {
+		t.Fatal("breaker did not admit a new trial")
+	}
+	b.success()
+	if !b.acquire() || !b.acquire() {
+		t.Fatal("breaker stayed open after a successful trial")
+	}
+}
+
+func TestBreaker_ReplacesLostTrial(t *testing.T) {
+	b := newBreaker(1, 20*time.Millisecond)
+	b.failure()
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.acquire() {
+		t.Fatal("breaker did not admit a trial")
+	}
+	// the trial never reports back
+	time.Sleep(30 * time.Millisecond)
+	if !b.acquire() {
+		t.Fatal("breaker did not admit another trial after the first one was lost")
+	}
+}
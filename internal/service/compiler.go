@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoHealthyBackend is returned when every backend of a language has its circuit breaker open.
+var ErrNoHealthyBackend = errors.New("no healthy executor backend available")
+
+// Compiler load balances executions of one language over its pool of executor backends.
+type Compiler struct {
+	strategy string
+	failover *config.Failover
+	backends []*backend
+	next     atomic.Uint64
+}
+
+// backend is a single executor instance together with the number of streams currently open on it
+// and the circuit breaker guarding it.
+type backend struct {
+	address string
+	client  compiler_service.CodeExecutorClient
+	health  grpc_health_v1.HealthClient
+	breaker *breaker
+	active  atomic.Int64
+}
+
+// newCompiler builds the pool for entry, reusing the backends of previous that kept their address
+// so that active session counts and breaker state survive a registry reload.
+func newCompiler(entry repos.Executor, previous *Compiler, cfg *config.Config) *Compiler {
+	reuse := make(map[string]*backend)
+	if previous != nil {
+		for _, b := range previous.backends {
+			reuse[b.address] = b
+		}
+	}
+
+	c := &Compiler{
+		strategy: entry.LoadBalancing,
+		failover: cfg.FailoverCfg,
+	}
+	for _, eb := range entry.Backends {
+		b, ok := reuse[eb.Address]
+		if !ok {
+			b = &backend{
+				address: eb.Address,
+				breaker: newBreaker(cfg.HealthCfg.FailureThreshold, cfg.HealthCfg.OpenTimeout),
+			}
+		}
+		b.client = eb.Client
+		b.health = eb.Health
+		c.backends = append(c.backends, b)
+	}
+	return c
+}
+
+// FailoverError is returned by Compiler.Open when no backend accepted the execution.
+type FailoverError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *FailoverError) Error() string {
+	return fmt.Sprintf("execution failed on %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *FailoverError) Unwrap() error {
+	return e.Err
+}
+
+// Open starts an execution stream and sends req on it. When opening the stream or sending
+// the first message fails, it retries with exponential backoff on the other healthy backends
+// of the pool, and on the already tried ones once every healthy backend has failed.
+func (c *Compiler) Open(ctx context.Context, req *compiler_service.ExecuteRequest) (compiler_service.CodeExecutor_ExecuteClient, error) {
+	attempts := max(c.failover.Attempts, 1)
+	backoff := c.failover.Backoff
+	tried := make(map[*backend]bool, len(c.backends))
+
+	var errs []error
+	for attempt := range attempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, &FailoverError{Attempts: len(errs), Err: errors.Join(append(errs, ctx.Err())...)}
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, max(c.failover.MaxBackoff, c.failover.Backoff))
+		}
+
+		b := c.pick(tried)
+		if b == nil && len(tried) > 0 {
+			clear(tried)
+			b = c.pick(tried)
+		}
+		if b == nil {
+			break
+		}
+		tried[b] = true
+
+		stream, err := c.open(ctx, b, req)
+		if err == nil {
+			return stream, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.address, err))
+	}
+
+	if len(errs) == 0 {
+		return nil, ErrNoHealthyBackend
+	}
+	return nil, &FailoverError{Attempts: len(errs), Err: errors.Join(errs...)}
+}
+
+// open starts a stream on b and sends req on it, reporting failures to b's circuit breaker
+// unless they are due to the caller giving up.
+func (c *Compiler) open(ctx context.Context, b *backend, req *compiler_service.ExecuteRequest) (compiler_service.CodeExecutor_ExecuteClient, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+
+	b.active.Add(1)
+	stream, err := b.client.Execute(streamCtx)
+	if err != nil {
+		cancel()
+		b.active.Add(-1)
+		if !callerGone(ctx, err) {
+			b.breaker.failure()
+		}
+		return nil, err
+	}
+
+	ts := &trackedStream{CodeExecutor_ExecuteClient: stream, backend: b}
+	ts.release = func() {
+		ts.once.Do(func() {
+			b.active.Add(-1)
+			cancel()
+		})
+	}
+	context.AfterFunc(streamCtx, ts.release)
+
+	if err := stream.Send(req); err != nil {
+		if err == io.EOF {
+			// the stream was aborted, the actual status is reported by Recv
+			if recvErr := stream.RecvMsg(new(compiler_service.ExecuteResponse)); recvErr != nil && recvErr != io.EOF {
+				err = recvErr
+			}
+		}
+		ts.release()
+		if !callerGone(ctx, err) {
+			b.breaker.failure()
+		}
+		return nil, err
+	}
+	return ts, nil
+}
+
+// callerGone reports whether a call failed with err because its caller, whose context is ctx,
+// went away or stopped the execution, which says nothing about the health of the backend.
+func callerGone(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded:
+		return true
+	}
+	return ctx.Err() != nil
+}
+
+// available reports whether at least one backend accepts executions.
+func (c *Compiler) available() bool {
+	for _, b := range c.backends {
+		if b.breaker.allow() {
+			return true
+		}
+	}
+	return false
+}
+
+// pick selects the backend for the next execution among those not in exclude,
+// or nil if every remaining breaker is open or the pool is empty. Picking a backend
+// whose breaker is half-open claims its trial execution.
+func (c *Compiler) pick(exclude map[*backend]bool) *backend {
+	n := len(c.backends)
+	if n == 0 {
+		return nil
+	}
+	// start from a rotating offset so that round robin advances and least-active ties are spread over the pool
+	offset := int(c.next.Add(1) % uint64(n))
+
+	// a candidate can lose the trial of its half-open breaker to a concurrent pick
+	var lost map[*backend]bool
+	for {
+		var best *backend
+		for i := range n {
+			b := c.backends[(offset+i)%n]
+			if exclude[b] || lost[b] || !b.breaker.allow() {
+				continue
+			}
+			if c.strategy != repos.LeastActive {
+				best = b
+				break
+			}
+			if best == nil || b.active.Load() < best.active.Load() {
+				best = b
+			}
+		}
+		if best == nil || best.breaker.acquire() {
+			return best
+		}
+		if lost == nil {
+			lost = make(map[*backend]bool)
+		}
+		lost[best] = true
+	}
+}
+
+// trackedStream releases its backend's active session slot once the stream ends,
+// either by an error from Recv (including io.EOF) or by cancellation of its context.
+// The first Recv result also reports the backend's health to its circuit breaker.
+type trackedStream struct {
+	compiler_service.CodeExecutor_ExecuteClient
+	backend  *backend
+	once     sync.Once
+	release  func()
+	reported atomic.Bool
+}
+
+func (t *trackedStream) Recv() (*compiler_service.ExecuteResponse, error) {
+	resp, err := t.CodeExecutor_ExecuteClient.Recv()
+	if err != nil {
+		t.release()
+	}
+	if !t.reported.Swap(true) {
+		if err == nil || err == io.EOF {
+			t.backend.breaker.success()
+		} else if status.Code(err) == codes.Unavailable {
+			t.backend.breaker.failure()
+		} else {
+			t.reported.Store(false)
+		}
+	}
+	return resp, err
+}
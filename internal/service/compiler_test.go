@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+)
+
+func codeRequest() *compiler_service.ExecuteRequest {
+	return &compiler_service.ExecuteRequest{
+		SessionId: "s1",
+		Payload:   &compiler_service.ExecuteRequest_Code{Code: &compiler_service.Code{Language: "python", SourceCode: "print(1)"}},
+	}
+}
+
+func testCompiler(t *testing.T, strategy string, servers ...*fakeexec.Server) *Compiler {
+	t.Helper()
+	entry := repos.Executor{Language: "python", LoadBalancing: strategy}
+	for _, s := range servers {
+		entry.Backends = append(entry.Backends, s.Backend())
+	}
+	return newCompiler(entry, nil, config.NewConfig())
+}
+
+// activeCounts returns the number of open streams of every backend of c.
+func activeCounts(c *Compiler) []int64 {
+	counts := make([]int64, len(c.backends))
+	for i, b := range c.backends {
+		counts[i] = b.active.Load()
+	}
+	return counts
+}
+
+func waitActive(t *testing.T, c *Compiler, want ...int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := activeCounts(c)
+		if slices.Equal(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got active streams %v, want %v", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCompiler_LeastActivePicksLeastLoaded(t *testing.T) {
+	servers := []*fakeexec.Server{fakeexec.NewServer(t), fakeexec.NewServer(t), fakeexec.NewServer(t)}
+	for _, s := range servers {
+		s.SetScript(fakeexec.Hang())
+	}
+	c := testCompiler(t, repos.LeastActive, servers...)
+
+	// the first two backends are busy with three streams
+	streams := make(map[*backend][]context.CancelFunc)
+	for _, i := range []int{0, 0, 1} {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		if _, err := c.open(ctx, c.backends[i], codeRequest()); err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		streams[c.backends[i]] = append(streams[c.backends[i]], cancel)
+	}
+	waitActive(t, c, 2, 1, 0)
+
+	for range 5 {
+		if b := c.pick(nil); b != c.backends[2] {
+			t.Fatalf("picked %s, want the idle backend %s", b.address, c.backends[2].address)
+		}
+	}
+
+	// closing the streams of the first backend makes it the least loaded one
+	for _, cancel := range streams[c.backends[0]] {
+		cancel()
+	}
+	waitActive(t, c, 0, 1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if _, err := c.Open(ctx, codeRequest()); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if _, err := c.Open(ctx, codeRequest()); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitActive(t, c, 1, 1, 1)
+}
+
+func TestCompiler_ReleasesEndedStreams(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("done"))
+	c := testCompiler(t, repos.LeastActive, exec)
+
+	stream, err := c.Open(context.Background(), codeRequest())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	waitActive(t, c, 1)
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("Recv: %v", err)
+			}
+			break
+		}
+	}
+	waitActive(t, c, 0)
+}
+
+func TestCompiler_CallerGoneKeepsBreakerClosed(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	c := testCompiler(t, repos.LeastActive, exec)
+	b := c.backends[0]
+
+	// clients going away before their execution started do not count against the backend
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 2 * config.NewConfig().HealthCfg.FailureThreshold {
+		if _, err := c.open(ctx, b, codeRequest()); err == nil {
+			t.Fatal("open succeeded with a cancelled context")
+		}
+	}
+	if b.breaker.isOpen() {
+		t.Fatal("breaker opened by cancelled calls")
+	}
+
+	// while an unreachable backend still trips it
+	exec.Down()
+	for range config.NewConfig().HealthCfg.FailureThreshold {
+		c.open(context.Background(), b, codeRequest())
+	}
+	if !b.breaker.isOpen() {
+		t.Fatal("breaker still closed after the backend failed")
+	}
+}
+
+func TestCompiler_RoundRobin(t *testing.T) {
+	servers := []*fakeexec.Server{fakeexec.NewServer(t), fakeexec.NewServer(t)}
+	c := testCompiler(t, "", servers...)
+
+	first, second := c.pick(nil), c.pick(nil)
+	if first == second || c.pick(nil) != first {
+		t.Fatalf("picked %s, %s, want the backends in turn", first.address, second.address)
+	}
+}
+
+func TestCompiler_EmptyPool(t *testing.T) {
+	c := testCompiler(t, repos.LeastActive)
+
+	if b := c.pick(nil); b != nil {
+		t.Fatalf("picked %s from an empty pool", b.address)
+	}
+	if _, err := c.Open(context.Background(), codeRequest()); !errors.Is(err, ErrNoHealthyBackend) {
+		t.Fatalf("got %v, want %v", err, ErrNoHealthyBackend)
+	}
+}
@@ -0,0 +1,168 @@
+package service_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ugorji/go/codec"
+)
+
+func keepaliveGateway(t *testing.T, exec *fakeexec.Server, configure func(cfg *config.WebSocket)) *wstest.Client {
+	t.Helper()
+	cfg := testutil.Config()
+	configure(cfg.WsCfg)
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	return wstest.Dial(t, gw.ExecuteURL(), nil)
+}
+
+func TestExecuteWithWs_MessageTooBig(t *testing.T) {
+	c := keepaliveGateway(t, fakeexec.NewServer(t), func(cfg *config.WebSocket) { cfg.MaxMessageBytes = 1024 })
+
+	c.Send(service.WsMessage{Language: "python", Code: strings.Repeat("#", 2048)})
+	expectCloseCode(t, c, websocket.CloseMessageTooBig, "")
+}
+
+func TestExecuteWithWs_ClosesIdleConnection(t *testing.T) {
+	c := keepaliveGateway(t, fakeexec.NewServer(t), func(cfg *config.WebSocket) { cfg.IdleTimeout = 50 * time.Millisecond })
+
+	expectCloseCode(t, c, websocket.CloseNormalClosure, "idle timeout")
+}
+
+func TestExecuteWithWs_IdleTimeoutSparesRunningExecution(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Delay(200*time.Millisecond),
+		fakeexec.Output("done\n"),
+	)
+	c := keepaliveGateway(t, exec, func(cfg *config.WebSocket) { cfg.IdleTimeout = 50 * time.Millisecond })
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "done")
+	expectClosed(t, c)
+	expectCloseCode(t, c, websocket.CloseNormalClosure, "idle timeout")
+}
+
+func TestExecuteWithWs_KeepaliveAnsweredByClient(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Delay(300*time.Millisecond),
+		fakeexec.Output("done\n"),
+	)
+	c := keepaliveGateway(t, exec, func(cfg *config.WebSocket) {
+		cfg.PingInterval = 20 * time.Millisecond
+		cfg.PongTimeout = 100 * time.Millisecond
+	})
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "done")
+}
+
+func TestExecuteWithWs_KeepaliveTimeout(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	c := keepaliveGateway(t, exec, func(cfg *config.WebSocket) {
+		cfg.PingInterval = 20 * time.Millisecond
+		cfg.PongTimeout = 100 * time.Millisecond
+	})
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	exec.WaitOpen(1, wstest.DefaultTimeout)
+	// not reading leaves the pings unanswered
+	time.Sleep(300 * time.Millisecond)
+
+	for {
+		_, err := c.TryNext(wstest.DefaultTimeout)
+		if err == nil {
+			continue
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatalf("connection still open, want it closed after unanswered pings")
+		}
+		break
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestTypedProtocol_MessagePackSubprotocol(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("hello\n"),
+		fakeexec.Status("EXIT_CODE:0"),
+	)
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	dialer := &websocket.Dialer{Subprotocols: []string{service.SubprotocolMsgpack}, EnableCompression: true}
+	c := wstest.DialWith(t, dialer, gw.ExecuteURL(), nil)
+
+	if got := c.Conn.Subprotocol(); got != service.SubprotocolMsgpack {
+		t.Fatalf("got subprotocol %q, want %q", got, service.SubprotocolMsgpack)
+	}
+	if ext := c.Response.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("got extensions %q, want permessage-deflate", ext)
+	}
+
+	handle := &codec.MsgpackHandle{}
+	handle.RawToString = true
+	var payload []byte
+	if err := codec.NewEncoderBytes(&payload, handle).Encode(map[string]any{"type": "run", "id": "r1", "language": "python", "code": "print(1)"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Conn.WriteMessage(websocket.BinaryMessage, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	nextMsgpack := func() map[string]any {
+		t.Helper()
+		c.Conn.SetReadDeadline(time.Now().Add(wstest.DefaultTimeout))
+		msgType, payload, err := c.Conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if msgType != websocket.BinaryMessage {
+			t.Fatalf("got frame type %d with %s, want a binary frame", msgType, payload)
+		}
+		var ev map[string]any
+		if err := codec.NewDecoderBytes(payload, handle).Decode(&ev); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return ev
+	}
+
+	if ev := nextMsgpack(); ev["type"] != service.EventQueued || ev["id"] != "r1" {
+		t.Fatalf("got %v, want queued event", ev)
+	}
+	if ev := nextMsgpack(); ev["type"] != service.EventOutput || ev["output"] != "hello\n" || ev["stream"] != service.StreamStdout {
+		t.Fatalf("got %v, want stdout output", ev)
+	}
+	nextMsgpack() // EXIT_CODE status
+	ev := nextMsgpack()
+	if ev["type"] != service.EventExit || ev["reason"] != service.ExitCompleted {
+		t.Fatalf("got %v, want exit event", ev)
+	}
+	if _, ok := ev["wall_time_ms"]; !ok {
+		t.Fatalf("got %v, want the exit info inlined", ev)
+	}
+}
+
+func TestExecuteWithWs_CompressedJSON(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output(strings.Repeat("hello ", 100)))
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	c := wstest.DialWith(t, &websocket.Dialer{EnableCompression: true}, gw.ExecuteURL(), nil)
+
+	if got := c.Conn.Subprotocol(); got != "" {
+		t.Fatalf("got subprotocol %q, want none", got)
+	}
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "hello hello")
+	expectClosed(t, c)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// gatedStream is the stream of a run that records what the gateway sends it. Its first Send
+// blocks until released, so that a control request stays in flight.
+type gatedStream struct {
+	grpc.ClientStream
+	sending chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (g *gatedStream) Send(req *compiler_service.ExecuteRequest) error {
+	g.mu.Lock()
+	g.calls = append(g.calls, "input "+req.GetInput().GetInputText())
+	first := len(g.calls) == 1
+	g.mu.Unlock()
+	if first {
+		close(g.sending)
+		<-g.release
+	}
+	return nil
+}
+
+func (g *gatedStream) CloseSend() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = append(g.calls, "close")
+	return nil
+}
+
+func (g *gatedStream) Recv() (*compiler_service.ExecuteResponse, error) {
+	return nil, io.EOF
+}
+
+func TestControl_SerialisesRequestsOfASession(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	srv := NewService(&lgg.Logger{Logger: log}, fakeexec.NewRegistry(), config.NewConfig())
+	sess := srv.newSession(context.Background(), nil, time.Minute)
+	sess.typed.Store(true)
+	t.Cleanup(sess.close)
+
+	stream := &gatedStream{sending: make(chan struct{}), release: make(chan struct{})}
+	sess.runs["r1"] = &run{id: "r1", runID: "r1", sessionID: sess.id, stream: stream, cancel: func() {}}
+
+	// two input requests closing stdin arrive together, as concurrent HTTP requests would
+	results := make(chan *WsError, 2)
+	control := func(input string) {
+		results <- srv.Control(sess.id, WsMessage{Type: MsgInput, Input: input, EOF: true})
+	}
+	go control("a")
+	<-stream.sending
+	go control("b")
+
+	select {
+	case werr := <-results:
+		t.Fatalf("got %v while the first request was still sending, want the second one to wait", werr)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(stream.release)
+
+	if werr := <-results; werr != nil {
+		t.Fatalf("got %v, want the first input accepted", werr)
+	}
+	if werr := <-results; werr == nil || werr.Code != ErrInputClosed {
+		t.Fatalf("got %v, want %s for the input sent after EOF", werr, ErrInputClosed)
+	}
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if want := []string{"input a", "close"}; !slices.Equal(stream.calls, want) {
+		t.Fatalf("got %s, want %s", fmt.Sprint(stream.calls), fmt.Sprint(want))
+	}
+}
+
+// stalledOutbox is the connection of a client that does not read its events: sends block until released.
+// Each send is announced on sending, which must have room for them all.
+type stalledOutbox struct {
+	sending chan struct{}
+	release chan struct{}
+
+	mu   sync.Mutex
+	seqs []uint64
+}
+
+func (o *stalledOutbox) send(v any) error {
+	o.sending <- struct{}{}
+	<-o.release
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seqs = append(o.seqs, v.(WsEvent).Seq)
+	return nil
+}
+
+func (o *stalledOutbox) drop() {}
+
+func TestControl_DoesNotWaitForSlowClients(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	srv := NewService(&lgg.Logger{Logger: log}, fakeexec.NewRegistry(), config.NewConfig())
+	out := &stalledOutbox{sending: make(chan struct{}, 2), release: make(chan struct{})}
+	sess := srv.newSession(context.Background(), out, time.Minute)
+	sess.typed.Store(true)
+	t.Cleanup(sess.close)
+
+	stream := &gatedStream{sending: make(chan struct{}), release: make(chan struct{})}
+	close(stream.release)
+	sess.runs["r1"] = &run{id: "r1", runID: "r1", sessionID: sess.id, stream: stream, cancel: func() {}}
+
+	// the client stops reading while the run emits its output
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		sess.emit(WsEvent{Type: EventOutput, RunID: "r1", Output: "1"})
+		sess.emit(WsEvent{Type: EventOutput, RunID: "r1", Output: "2"})
+	}()
+	<-out.sending
+
+	result := make(chan *WsError)
+	go func() { result <- srv.Control(sess.id, WsMessage{Type: MsgInput, Input: "a"}) }()
+	select {
+	case werr := <-result:
+		if werr != nil {
+			t.Fatalf("got %v, want the input accepted", werr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("input waited for the client to read its events")
+	}
+
+	close(out.release)
+	<-emitted
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if want := []uint64{1, 2}; !slices.Equal(out.seqs, want) {
+		t.Fatalf("got events %v, want %v in order", out.seqs, want)
+	}
+}
+
+func TestSession_BuffersEventsOnlyWhenResumable(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	srv := NewService(&lgg.Logger{Logger: log}, fakeexec.NewRegistry(), config.NewConfig())
+
+	for _, typed := range []bool{false, true} {
+		sess := srv.newSession(context.Background(), nil, time.Minute)
+		sess.typed.Store(typed)
+		sess.emit(WsEvent{Type: EventOutput, RunID: "r1", Output: "out"})
+		sess.close()
+		if buffered := len(sess.events) > 0; buffered != typed {
+			t.Fatalf("typed %t: got %d buffered event(s), want buffering only for typed sessions", typed, len(sess.events))
+		}
+	}
+}
+
+// drainingStream is the stream of an executor that, like the local one, ends with EOF once the
+// run is cancelled rather than with the cancellation.
+type drainingStream struct {
+	grpc.ClientStream
+	ctx context.Context
+}
+
+func (d *drainingStream) Send(*compiler_service.ExecuteRequest) error { return nil }
+
+func (d *drainingStream) CloseSend() error { return nil }
+
+func (d *drainingStream) Recv() (*compiler_service.ExecuteResponse, error) {
+	<-d.ctx.Done()
+	return nil, io.EOF
+}
+
+// recordingOutbox is a connection recording the events sent to it.
+type recordingOutbox struct {
+	events chan WsEvent
+}
+
+func (o *recordingOutbox) send(v any) error {
+	o.events <- v.(WsEvent)
+	return nil
+}
+
+func (o *recordingOutbox) drop() {}
+
+func TestControl_StoppedRunEndingWithEOF(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	srv := NewService(&lgg.Logger{Logger: log}, fakeexec.NewRegistry(), config.NewConfig())
+	out := &recordingOutbox{events: make(chan WsEvent, 16)}
+	sess := srv.newSession(context.Background(), out, time.Minute)
+	sess.typed.Store(true)
+	t.Cleanup(sess.close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &run{id: "r1", runID: "r1", sessionID: sess.id, stream: &drainingStream{ctx: ctx}, cancel: cancel, started: time.Now()}
+	sess.runs["r1"] = r
+	go sess.forward(r)
+
+	if werr := srv.Control(sess.id, WsMessage{Type: MsgStop}); werr != nil {
+		t.Fatalf("got %v, want the run stopped", werr)
+	}
+	for {
+		select {
+		case ev := <-out.events:
+			if ev.Type != EventExit {
+				continue
+			}
+			if ev.Reason != ExitStopped {
+				t.Fatalf("got exit reason %q, want %q", ev.Reason, ExitStopped)
+			}
+			return
+		case <-time.After(2 * time.Second):
+			t.Fatal("the run did not exit")
+		}
+	}
+}
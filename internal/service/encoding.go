@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Subprotocols of the /execute endpoint, requested with the Sec-WebSocket-Protocol header.
+// Connections without a subprotocol, or with SubprotocolJSON, exchange JSON text frames.
+// SubprotocolMsgpack exchanges the same messages as MessagePack maps in binary frames,
+// keyed by the JSON field names; its clients may still send JSON text frames.
+const (
+	SubprotocolJSON    = "compiler.v1.json"
+	SubprotocolMsgpack = "compiler.v1.msgpack"
+)
+
+// Subprotocols lists the subprotocols the gateway accepts, in order of preference.
+var Subprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	return h
+}()
+
+// encodeMessage encodes v as a frame of the given subprotocol.
+func encodeMessage(subprotocol string, v any) (int, []byte, error) {
+	if subprotocol == SubprotocolMsgpack {
+		var payload []byte
+		err := codec.NewEncoderBytes(&payload, msgpackHandle).Encode(v)
+		return websocket.BinaryMessage, payload, err
+	}
+	payload, err := json.Marshal(v)
+	return websocket.TextMessage, payload, err
+}
+
+// decodeMsgpack decodes a client message sent as MessagePack.
+func decodeMsgpack(payload []byte, msg *WsMessage) error {
+	return codec.NewDecoderBytes(payload, msgpackHandle).Decode(msg)
+}
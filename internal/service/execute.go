@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Result is the outcome of an execution driven to completion by Execute. Reason is the reason of
+// the exit event, Limit the output or time limit that stopped the program, if any, and Error the
+// first error raised by the gateway once the program was running.
+type Result struct {
+	Stdout string
+	Stderr string
+	Reason string
+	Limit  *LimitInfo
+	Error  *WsError
+	ExitInfo
+}
+
+// collector is the outbox of a session run by Execute: it gathers the events of the run into its result.
+type collector struct {
+	mu     sync.Mutex
+	stdout strings.Builder
+	stderr strings.Builder
+	result Result
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Execute runs the code of msg with msg.Stdin as its whole input and waits for it to end, for
+// clients that want the output rather than a stream of events. The execution goes through the
+// same checks and limits as a run message; the error that prevented it from starting is returned
+// instead of a result. Cancelling ctx stops the execution.
+func (s *Service) Execute(ctx context.Context, msg WsMessage) (*Result, *WsError) {
+	return s.execute(ctx, msg, 0)
+}
+
+// execute is Execute with the wall time limit lowered to wallTime, if not 0.
+func (s *Service) execute(ctx context.Context, msg WsMessage, wallTime time.Duration) (*Result, *WsError) {
+	out := &collector{done: make(chan struct{})}
+	sess := s.newSession(ctx, out, 0)
+	sess.typed.Store(true)
+	sess.wallTime = wallTime
+	defer sess.close()
+
+	msg.Type = MsgRun
+	msg.EOF = true
+	if msg.RunID == "" {
+		msg.RunID = uuid.NewString()
+	}
+	werr, err := sess.call(msg)
+	if werr == nil && err != nil {
+		werr = &WsError{Code: ErrInvalidMessage, Message: err.Error()}
+	}
+	if werr != nil {
+		return nil, werr
+	}
+
+	select {
+	case <-out.done:
+	case <-ctx.Done():
+		s.logger.Warn("Client gave up waiting for execution", map[string]any{"session_id": sess.id, "run_id": msg.RunID})
+		return nil, &WsError{Code: ErrConnection, Message: ctx.Err().Error()}
+	}
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	result := out.result
+	result.Stdout, result.Stderr = out.stdout.String(), out.stderr.String()
+	return &result, nil
+}
+
+func (c *collector) send(v any) error {
+	ev, ok := v.(WsEvent)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch ev.Type {
+	case EventOutput:
+		if ev.Stream == StreamStderr {
+			c.stderr.WriteString(ev.Output)
+		} else {
+			c.stdout.WriteString(ev.Output)
+		}
+	case EventTruncated, EventTimeout:
+		c.result.Limit = ev.Limit
+	case EventError:
+		if c.result.Error == nil {
+			c.result.Error = ev.Error
+		}
+	case EventExit:
+		c.result.Reason = ev.Reason
+		if ev.ExitInfo != nil {
+			c.result.ExitInfo = *ev.ExitInfo
+		}
+		c.once.Do(func() { close(c.done) })
+	}
+	return nil
+}
+
+// drop has nothing to disconnect, the result is only read once the run has ended.
+func (c *collector) drop() {}
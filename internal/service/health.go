@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Start launches the periodic health probes of every executor backend.
+func (s *Service) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopProbes = cancel
+	s.probesDone = make(chan struct{})
+
+	go func() {
+		defer close(s.probesDone)
+		if s.cfg.HealthCfg.Interval <= 0 {
+			<-ctx.Done()
+			return
+		}
+
+		ticker := time.NewTicker(s.cfg.HealthCfg.Interval)
+		defer ticker.Stop()
+		for {
+			s.probeBackends(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the health probes started by Start.
+func (s *Service) Stop() {
+	if s.stopProbes == nil {
+		return
+	}
+	s.stopProbes()
+	<-s.probesDone
+}
+
+// probeBackends runs one gRPC health check against every backend concurrently
+// and feeds the results into the backends' circuit breakers.
+func (s *Service) probeBackends(ctx context.Context) {
+	s.executorsMu.RLock()
+	executors := s.executors
+	s.executorsMu.RUnlock()
+
+	var wg sync.WaitGroup
+	for language, pool := range executors {
+		for _, b := range pool.backends() {
+			if b.health == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.probeBackend(ctx, language, b)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (s *Service) probeBackend(ctx context.Context, language string, b *backend) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.HealthCfg.Timeout)
+	defer cancel()
+
+	resp, err := b.health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	switch {
+	case err == nil && resp.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING,
+		status.Code(err) == codes.Unimplemented: // executor does not expose the health service but is reachable
+		if b.breaker.isOpen() {
+			s.logger.Info("Executor backend recovered", map[string]any{"language": language, "address": b.address})
+		}
+		b.breaker.success()
+	case ctx.Err() != nil && status.Code(err) == codes.Canceled:
+		// the prober is shutting down
+	default:
+		if b.breaker.failure() {
+			s.logger.Warn("Executor backend marked unavailable", map[string]any{"language": language, "address": b.address, "error": err, "status": resp.GetStatus().String()})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// unimplementedHealth is the health client of an executor that does not expose the health service.
+type unimplementedHealth struct {
+	grpc_health_v1.HealthClient
+}
+
+func (unimplementedHealth) Check(context.Context, *grpc_health_v1.HealthCheckRequest, ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown service grpc.health.v1.Health")
+}
+
+func probedService(t *testing.T, executors ...repos.Executor) *Service {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := config.NewConfig()
+	cfg.HealthCfg.FailureThreshold = 1
+	return NewService(&lgg.Logger{Logger: log}, fakeexec.NewRegistry(executors...), cfg)
+}
+
+func TestProbeBackends_UpdatesAvailability(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	s := probedService(t, fakeexec.Executor("python", exec))
+
+	s.probeBackends(context.Background())
+	if !s.Languages()["python"].Available {
+		t.Fatal("python is unavailable although its backend is serving")
+	}
+
+	exec.SetServing(false)
+	s.probeBackends(context.Background())
+	if st := s.Languages()["python"]; st.Available || st.Versions[0].Available {
+		t.Fatalf("got %+v, want python unavailable while its backend is not serving", st)
+	}
+
+	exec.SetServing(true)
+	s.probeBackends(context.Background())
+	if !s.Languages()["python"].Available {
+		t.Fatal("python stayed unavailable after its backend recovered")
+	}
+}
+
+func TestProbeBackends_UnreachableBackend(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.Down()
+	s := probedService(t, fakeexec.Executor("python", exec))
+
+	s.probeBackends(context.Background())
+	if s.Languages()["python"].Available {
+		t.Fatal("python is available although its backend is down")
+	}
+}
+
+func TestProbeBackends_UnimplementedHealthIsHealthy(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	backend := exec.Backend()
+	backend.Health = unimplementedHealth{}
+	s := probedService(t, repos.Executor{Language: "python", Backends: []repos.Backend{backend}})
+
+	pool := s.executors["python"].versions[""]
+	pool.backends[0].breaker.failure()
+	if s.Languages()["python"].Available {
+		t.Fatal("python is available with its breaker open")
+	}
+
+	s.probeBackends(context.Background())
+	if !s.Languages()["python"].Available {
+		t.Fatal("a backend without the health service was not treated as healthy")
+	}
+}
@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func newGateway(t *testing.T, executors ...*fakeexec.Server) *wstest.Client {
+	t.Helper()
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", executors...)))
+	return wstest.Dial(t, gw.ExecuteURL(), nil)
+}
+
+func next(t *testing.T, c *wstest.Client) service.WsResponse {
+	t.Helper()
+	var resp service.WsResponse
+	c.NextJSON(&resp)
+	return resp
+}
+
+func expect(t *testing.T, c *wstest.Client, status, output string) service.WsResponse {
+	t.Helper()
+	resp := next(t, c)
+	if resp.Status != status || !strings.Contains(resp.Output, output) {
+		t.Fatalf("got %+v, want status %q with output containing %q", resp, status, output)
+	}
+	return resp
+}
+
+func expectClosed(t *testing.T, c *wstest.Client) {
+	t.Helper()
+	expect(t, c, "INFO", "closed by server")
+	expect(t, c, "STREAM_CLOSED", "")
+}
+
+func nextEvent(t *testing.T, c *wstest.Client) service.WsEvent {
+	t.Helper()
+	var ev service.WsEvent
+	c.NextJSON(&ev)
+	if ev.V != service.ProtocolVersion {
+		t.Fatalf("got event %+v with protocol version %d, want %d", ev, ev.V, service.ProtocolVersion)
+	}
+	return ev
+}
+
+func expectEvent(t *testing.T, c *wstest.Client, typ, id string) service.WsEvent {
+	t.Helper()
+	ev := nextEvent(t, c)
+	if ev.Type != typ || ev.ID != id {
+		t.Fatalf("got %+v, want %q event with id %q", ev, typ, id)
+	}
+	return ev
+}
+
+func expectCloseCode(t *testing.T, c *wstest.Client, code int, reason string) {
+	t.Helper()
+	for {
+		_, err := c.TryNext(wstest.DefaultTimeout)
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code || closeErr.Text != reason {
+			t.Fatalf("got %v, want close code %d with reason %q", err, code, reason)
+		}
+		return
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+// Statuses of a background job.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed" // the program ran, whatever its exit
+	JobFailed    = "failed"    // the program could not run
+)
+
+// Jobs runs executions in the background: Submit queues them and the workers started by Start
+// run the queued jobs of every gateway sharing the queue, through Execute.
+type Jobs struct {
+	logger *lgg.Logger
+	srv    *Service
+	queue  repos.JobQueue
+	cfg    *config.Jobs
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewJobs creates the job runner of srv on queue.
+func NewJobs(logger *lgg.Logger, srv *Service, queue repos.JobQueue, cfg *config.Config) *Jobs {
+	return &Jobs{
+		logger: logger,
+		srv:    srv,
+		queue:  queue,
+		cfg:    cfg.JobsCfg,
+	}
+}
+
+// Start starts the workers.
+func (j *Jobs) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	j.stop = cancel
+	for range j.cfg.Workers {
+		j.wg.Add(1)
+		go func() {
+			defer j.wg.Done()
+			j.work(ctx)
+		}()
+	}
+	j.logger.Info("Started job workers", map[string]any{"workers": j.cfg.Workers, "consumer": j.cfg.Consumer})
+}
+
+// Stop stops the workers. The jobs they were running are interrupted and stay queued,
+// to be run again when the gateway restarts or by another gateway.
+func (j *Jobs) Stop() {
+	if j.stop == nil {
+		return
+	}
+	j.stop()
+	j.wg.Wait()
+}
+
+// Submit checks the submission msg and queues it, returning the queued job.
+func (j *Jobs) Submit(ctx context.Context, msg WsMessage) (*models.Job, *WsError) {
+	id := uuid.NewString()
+	if _, _, _, werr := j.srv.admit(id, msg); werr != nil {
+		return nil, werr
+	}
+
+	job := &models.Job{
+		ID:        id,
+		Status:    JobQueued,
+		Language:  msg.Language,
+		Version:   msg.Version,
+		Code:      msg.Code,
+		Stdin:     msg.Stdin,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := j.queue.Push(ctx, job); err != nil {
+		j.logger.Error("Failed to queue job", map[string]any{"job_id": id, "error": err})
+		return nil, &WsError{Code: ErrQueueUnavailable, Message: fmt.Sprintf("Failed to queue the job: %v", err)}
+	}
+	j.logger.Info("Queued job", map[string]any{"job_id": id, "language": msg.Language})
+	return job, nil
+}
+
+// Get returns the job id.
+func (j *Jobs) Get(ctx context.Context, id string) (*models.Job, *WsError) {
+	job, err := j.queue.Get(ctx, id)
+	if errors.Is(err, repos.ErrJobNotFound) {
+		return nil, &WsError{Code: ErrJobNotFound, Message: fmt.Sprintf("Job '%s' does not exist or has expired", id)}
+	}
+	if err != nil {
+		j.logger.Error("Failed to read job", map[string]any{"job_id": id, "error": err})
+		return nil, &WsError{Code: ErrQueueUnavailable, Message: fmt.Sprintf("Failed to read the job: %v", err)}
+	}
+	return job, nil
+}
+
+// work runs queued jobs one after the other until ctx ends.
+func (j *Jobs) work(ctx context.Context) {
+	for {
+		job, err := j.queue.Next(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			j.logger.Error("Failed to read the job queue", map[string]any{"error": err})
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		j.run(ctx, job)
+	}
+}
+
+// run executes job and records its outcome. A job interrupted by ctx is left as it is, to be run again.
+func (j *Jobs) run(ctx context.Context, job *models.Job) {
+	if limit := j.cfg.MaxAttempts; limit > 0 && job.Attempts >= limit {
+		j.logger.Warn("Giving up job", map[string]any{"job_id": job.ID, "attempts": job.Attempts})
+		job.Error = &models.JobError{Code: ErrJobAbandoned, Message: fmt.Sprintf("The job was interrupted %d time(s) and given up", job.Attempts)}
+		j.finish(ctx, job, JobFailed)
+		return
+	}
+
+	started := time.Now().UTC()
+	job.Attempts++
+	job.Status, job.StartedAt = JobRunning, &started
+	if err := j.queue.Save(ctx, job); err != nil {
+		j.logger.Error("Failed to save job", map[string]any{"job_id": job.ID, "error": err})
+		return
+	}
+	j.logger.Info("Running job", map[string]any{"job_id": job.ID, "attempt": job.Attempts})
+
+	stopTouching := j.touch(ctx, job)
+	result, werr := j.srv.Execute(ctx, WsMessage{
+		ID:       job.ID,
+		Language: job.Language,
+		Version:  job.Version,
+		Code:     job.Code,
+		Stdin:    job.Stdin,
+	})
+	stopTouching()
+	if ctx.Err() != nil {
+		j.logger.Warn("Job interrupted, leaving it queued", map[string]any{"job_id": job.ID})
+		return
+	}
+	if werr != nil {
+		job.Error = &models.JobError{Code: werr.Code, Message: werr.Message}
+		j.finish(ctx, job, JobFailed)
+		return
+	}
+	job.Output = jobOutput(result)
+	j.finish(ctx, job, JobCompleted)
+}
+
+// touch keeps job from being claimed by another gateway while it runs, touching it three times
+// per ClaimAfter until the returned function is called.
+func (j *Jobs) touch(ctx context.Context, job *models.Job) func() {
+	if j.cfg.ClaimAfter <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(j.cfg.ClaimAfter / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := j.queue.Touch(ctx, job); err != nil && ctx.Err() == nil {
+					j.logger.Warn("Failed to touch job", map[string]any{"job_id": job.ID, "error": err})
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// finish records the final status of job and removes it from the queue.
+func (j *Jobs) finish(ctx context.Context, job *models.Job, status string) {
+	finished := time.Now().UTC()
+	job.Status, job.FinishedAt = status, &finished
+	if err := j.queue.Save(ctx, job); err != nil {
+		j.logger.Error("Failed to save job", map[string]any{"job_id": job.ID, "error": err})
+		return
+	}
+	if err := j.queue.Done(ctx, job); err != nil {
+		j.logger.Error("Failed to remove job from the queue", map[string]any{"job_id": job.ID, "error": err})
+	}
+	j.logger.Info("Finished job", map[string]any{"job_id": job.ID, "status": status})
+}
+
+func jobOutput(result *Result) *models.JobOutput {
+	out := &models.JobOutput{
+		Stdout:      result.Stdout,
+		Stderr:      result.Stderr,
+		ExitCode:    result.ExitCode,
+		Reason:      result.Reason,
+		WallTimeMs:  result.WallTimeMs,
+		TTFBMs:      result.TTFBMs,
+		OutputBytes: result.OutputBytes,
+		OutputLines: result.OutputLines,
+	}
+	if result.Limit != nil {
+		out.LimitName, out.LimitMax = result.Limit.Name, result.Limit.Max
+	}
+	if result.Error != nil {
+		out.ErrorCode, out.ErrorText = result.Error.Code, result.Error.Message
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Judge runs the code of msg against each of msg.Tests and returns the verdicts. Every test case
+// gets its own execution, fed its input as the whole stdin, and at most JudgeCfg.Concurrency run
+// at once. progress, if not nil, is called with each verdict as soon as it is known. The error
+// that prevented judging is returned instead of a result.
+func (s *Service) Judge(ctx context.Context, msg WsMessage, progress func(Verdict)) (*Judgement, *WsError) {
+	if werr := s.checkJudge(msg); werr != nil {
+		return nil, werr
+	}
+	return s.judge(ctx, msg, progress)
+}
+
+// startJudge judges msg in the background, reporting each verdict with a verdict event and the
+// result with a judged event, or an exit event with ExitStopped when a stop message ends it first.
+func (sess *session) startJudge(msg WsMessage) {
+	s := sess.s
+	if msg.RunID == "" {
+		msg.RunID = uuid.NewString()
+	}
+	if werr := s.checkJudge(msg); werr != nil {
+		sess.emit(errorEvent(msg.ID, werr.Code, werr.Message))
+		return
+	}
+
+	sess.mu.Lock()
+	_, running := sess.runs[msg.RunID]
+	_, judging := sess.judges[msg.RunID]
+	active := len(sess.runs) + len(sess.judges)
+	if running || judging {
+		sess.mu.Unlock()
+		sess.emit(errorEvent(msg.ID, ErrInvalidMessage, fmt.Sprintf("Run '%s' is already active", msg.RunID)))
+		return
+	}
+	if limit := s.cfg.WsCfg.MaxRuns; limit > 0 && active >= limit {
+		sess.mu.Unlock()
+		sess.emit(errorEvent(msg.ID, ErrTooManyRuns, fmt.Sprintf("At most %d executions may run at once on a connection", limit)))
+		return
+	}
+	ctx, cancel := context.WithCancel(sess.ctx)
+	sess.judges[msg.RunID] = cancel
+	sess.mu.Unlock()
+	sess.emit(WsEvent{Type: EventQueued, ID: msg.ID, RunID: msg.RunID, SessionID: sess.id})
+
+	go func() {
+		defer cancel()
+		judgement, werr := s.judge(ctx, msg, func(v Verdict) {
+			sess.emit(WsEvent{Type: EventVerdict, ID: msg.ID, RunID: msg.RunID, Verdict: &v})
+		})
+
+		sess.mu.Lock()
+		delete(sess.judges, msg.RunID)
+		sess.mu.Unlock()
+		if sess.ctx.Err() != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			sess.emit(WsEvent{Type: EventExit, ID: msg.ID, RunID: msg.RunID, Reason: ExitStopped})
+			return
+		}
+		if werr != nil {
+			ev := errorEvent(msg.ID, werr.Code, werr.Message)
+			ev.RunID = msg.RunID
+			sess.emit(ev)
+			return
+		}
+		sess.emit(WsEvent{Type: EventJudged, ID: msg.ID, RunID: msg.RunID, Judge: judgement})
+	}()
+}
+
+// stopJudge cancels the judge targeted by msg: the one with its run id, or the only activity of the
+// session when msg has none. It reports whether msg targeted a judge.
+func (sess *session) stopJudge(msg WsMessage) bool {
+	sess.mu.Lock()
+	cancel, ok := sess.judges[msg.RunID]
+	if msg.RunID == "" && len(sess.runs) == 0 && len(sess.judges) == 1 {
+		for _, c := range sess.judges {
+			cancel, ok = c, true
+		}
+	}
+	sess.mu.Unlock()
+	if ok {
+		sess.s.logger.Info("Stopping judge on client request", map[string]any{"session_id": sess.id, "run_id": msg.RunID})
+		cancel()
+	}
+	return ok
+}
+
+// checkJudge returns the error that keeps the judge message msg from being judged.
+func (s *Service) checkJudge(msg WsMessage) *WsError {
+	switch limit := s.cfg.JudgeCfg.MaxTests; {
+	case msg.Language == "" || msg.Code == "" || len(msg.Tests) == 0:
+		return &WsError{Code: ErrInvalidMessage, Message: "A judge message requires 'language', 'code' and 'tests'"}
+	case limit > 0 && len(msg.Tests) > limit:
+		return &WsError{Code: ErrInvalidMessage, Message: fmt.Sprintf("A judge message may have at most %d tests", limit)}
+	case msg.TimeLimitMs < 0:
+		return &WsError{Code: ErrInvalidMessage, Message: "'time_limit_ms' must not be negative"}
+	}
+	_, _, _, werr := s.admit(msg.ID, msg)
+	return werr
+}
+
+func (s *Service) judge(ctx context.Context, msg WsMessage, progress func(Verdict)) (*Judgement, *WsError) {
+	cfg := s.cfg.JudgeCfg
+	limit := cfg.TimeLimit
+	if requested := time.Duration(msg.TimeLimitMs) * time.Millisecond; requested > 0 && (limit == 0 || requested < limit) {
+		limit = requested
+	}
+	s.logger.Info("Judging submission", map[string]any{"run_id": msg.RunID, "language": msg.Language, "tests": len(msg.Tests), "time_limit": limit.String()})
+
+	started := time.Now()
+	verdicts := make([]Verdict, len(msg.Tests))
+	slots := make(chan struct{}, max(cfg.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, tc := range msg.Tests {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			verdicts[i] = s.judgeCase(ctx, msg, i, tc, limit)
+			if progress != nil && ctx.Err() == nil {
+				progress(verdicts[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, &WsError{Code: ErrConnection, Message: err.Error()}
+	}
+
+	judgement := &Judgement{Verdict: VerdictAccepted, Total: len(verdicts), TimeMs: time.Since(started).Milliseconds(), Tests: verdicts}
+	for _, v := range verdicts {
+		if v.Verdict == VerdictAccepted {
+			judgement.Passed++
+		} else if judgement.Verdict == VerdictAccepted {
+			judgement.Verdict = v.Verdict
+		}
+	}
+	s.logger.Info("Judged submission", map[string]any{"run_id": msg.RunID, "verdict": judgement.Verdict, "passed": judgement.Passed, "total": judgement.Total})
+	return judgement, nil
+}
+
+// judgeCase runs test case i of msg within limit and returns its verdict.
+func (s *Service) judgeCase(ctx context.Context, msg WsMessage, i int, tc TestCase, limit time.Duration) Verdict {
+	result, werr := s.execute(ctx, WsMessage{
+		ID:       msg.ID,
+		Language: msg.Language,
+		Version:  msg.Version,
+		Code:     msg.Code,
+		Stdin:    tc.Input,
+	}, limit)
+	if werr != nil {
+		return Verdict{Test: i, Verdict: VerdictInternalError, Error: werr}
+	}
+
+	v := Verdict{
+		Test:     i,
+		TimeMs:   result.WallTimeMs,
+		ExitCode: result.ExitCode,
+		Reason:   result.Reason,
+		Stdout:   result.Stdout,
+		Stderr:   result.Stderr,
+	}
+	switch {
+	case result.Reason == ExitTimeout:
+		v.Verdict = VerdictTimeLimit
+	case result.Reason == ExitTruncated:
+		v.Verdict = VerdictOutputLimit
+	case result.Reason == ExitCancelled:
+		// the execution was cut short by the executor or the gateway, not ended by the program
+		v.Verdict = VerdictInternalError
+		v.Error = &WsError{Code: ErrStreamFailed, Message: "The execution was cancelled before it ended"}
+	case result.Reason == ExitFailed:
+		// the stream to the executor failed, which says nothing about the program
+		v.Verdict = VerdictInternalError
+		v.Error = result.Error
+	case result.ExitCode != nil && *result.ExitCode != 0:
+		// only the local executor reports exit codes, remote programs are judged on their output alone
+		v.Verdict = VerdictRuntimeError
+	case result.Reason == ExitCompleted && normalizeOutput(result.Stdout) == normalizeOutput(tc.Expected):
+		v.Verdict = VerdictAccepted
+	default:
+		v.Verdict = VerdictWrongAnswer
+	}
+	return v
+}
+
+// normalizeOutput drops the trailing whitespace of every line and the trailing blank lines,
+// which are not held against a submission.
+func normalizeOutput(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
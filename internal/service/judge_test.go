@@ -0,0 +1,129 @@
+package service_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+	"google.golang.org/grpc/codes"
+)
+
+// judgeScript adds the two numbers of its input, loses its stream on "boom", never ends on "loop", prints 0
+// with exit code 1 on "exit", floods its output on "flood" and is cancelled by the executor on "cancel".
+func judgeScript(exec *fakeexec.Server, delay time.Duration) {
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.Delay(delay), fakeexec.ByInput(func(input string) []fakeexec.Step {
+		switch input {
+		case "boom\n":
+			return []fakeexec.Step{fakeexec.Stderr("Traceback\n"), fakeexec.Fail(codes.Internal, "program crashed")}
+		case "loop\n":
+			return []fakeexec.Step{fakeexec.Hang()}
+		case "exit\n":
+			return []fakeexec.Step{fakeexec.Output("0\n"), fakeexec.Status(service.StateExitCode + "1")}
+		case "flood\n":
+			return []fakeexec.Step{fakeexec.Output(strings.Repeat("0\n", 100)), fakeexec.Hang()}
+		case "cancel\n":
+			return []fakeexec.Step{fakeexec.Fail(codes.Canceled, "executor shutting down")}
+		}
+		var a, b int
+		fmt.Sscan(input, &a, &b)
+		return []fakeexec.Step{fakeexec.Output(fmt.Sprintf("%d  \n\n", a+b)), fakeexec.Status(service.StateExitCode + "0")}
+	}))
+}
+
+func TestTypedProtocol_Judge(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	judgeScript(exec, 0)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgJudge, ID: "j1", Language: "python", Code: "print(sum(map(int, input().split())))", TimeLimitMs: 200, Tests: []service.TestCase{
+		{Input: "1 2\n", Expected: "3\n"},
+		{Input: "2 2\n", Expected: "5"},
+		{Input: "boom\n", Expected: "0"},
+		{Input: "loop\n", Expected: "0"},
+		{Input: "exit\n", Expected: "0"},
+	}})
+	if ev := expectEvent(t, c, service.EventQueued, "j1"); ev.RunID == "" {
+		t.Fatalf("got %+v, want the judge's run id", ev)
+	}
+
+	want := []string{service.VerdictAccepted, service.VerdictWrongAnswer, service.VerdictInternalError, service.VerdictTimeLimit, service.VerdictRuntimeError}
+	seen := make(map[int]bool)
+	for range want {
+		ev := expectEvent(t, c, service.EventVerdict, "j1")
+		if v := ev.Verdict; v == nil || seen[v.Test] || v.Verdict != want[v.Test] {
+			t.Fatalf("got verdict %+v, want one of %v per test", ev.Verdict, want)
+		}
+		seen[ev.Verdict.Test] = true
+	}
+
+	judged := expectEvent(t, c, service.EventJudged, "j1").Judge
+	if judged == nil || judged.Verdict != service.VerdictWrongAnswer || judged.Passed != 1 || judged.Total != 5 || len(judged.Tests) != 5 {
+		t.Fatalf("got %+v, want WA with 1 of 5 tests passed", judged)
+	}
+	if tle := judged.Tests[3]; tle.Reason != service.ExitTimeout || tle.TimeMs < 200 {
+		t.Fatalf("got %+v, want a timeout after the time limit", tle)
+	}
+	if ie := judged.Tests[2]; ie.Reason != service.ExitFailed || ie.Error == nil || ie.Stderr != "Traceback\n" {
+		t.Fatalf("got %+v, want the error and stderr of the failed stream", ie)
+	}
+	// a nonzero exit code is a runtime error even when the output before it matched
+	if re := judged.Tests[4]; re.ExitCode == nil || *re.ExitCode != 1 || re.Stdout != "0\n" {
+		t.Fatalf("got %+v, want the exit code and output reported", re)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestTypedProtocol_JudgeOutputLimitAndCancellation(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	judgeScript(exec, 0)
+	c := limitedGateway(t, exec, map[string]int{"*": 100}, nil)
+
+	c.Send(service.WsMessage{Type: service.MsgJudge, ID: "j1", Language: "python", Code: "print(0)", Tests: []service.TestCase{
+		{Input: "flood\n", Expected: "0"},
+		{Input: "cancel\n", Expected: "0"},
+	}})
+	expectEvent(t, c, service.EventQueued, "j1")
+	expectEvent(t, c, service.EventVerdict, "j1")
+	expectEvent(t, c, service.EventVerdict, "j1")
+	judged := expectEvent(t, c, service.EventJudged, "j1").Judge
+	if judged == nil || len(judged.Tests) != 2 {
+		t.Fatalf("got %+v, want both tests judged", judged)
+	}
+	if ole := judged.Tests[0]; ole.Verdict != service.VerdictOutputLimit || ole.Reason != service.ExitTruncated {
+		t.Fatalf("got %+v, want %s for the truncated output", ole, service.VerdictOutputLimit)
+	}
+	if ie := judged.Tests[1]; ie.Verdict != service.VerdictInternalError || ie.Error == nil {
+		t.Fatalf("got %+v, want %s for the cancelled execution", ie, service.VerdictInternalError)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestTypedProtocol_StopJudge(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	judgeScript(exec, 0)
+	c := newGateway(t, exec)
+	tests := []service.TestCase{{Input: "loop\n", Expected: "0"}, {Input: "loop\n", Expected: "0"}}
+
+	// by run id, and as the only activity of the connection
+	for _, stop := range []service.WsMessage{{Type: service.MsgStop, RunID: "judge-1"}, {Type: service.MsgStop}} {
+		c.Send(service.WsMessage{Type: service.MsgJudge, ID: "j1", RunID: "judge-1", Language: "python", Code: "print(0)", Tests: tests})
+		expectEvent(t, c, service.EventQueued, "j1")
+		exec.WaitOpen(2, wstest.DefaultTimeout)
+
+		c.Send(stop)
+		if ev := expectEvent(t, c, service.EventExit, "j1"); ev.RunID != "judge-1" || ev.Reason != service.ExitStopped {
+			t.Fatalf("got %+v, want the judge stopped", ev)
+		}
+		// its executions are cancelled rather than left running
+		exec.WaitIdle(wstest.DefaultTimeout)
+
+		c.Send(service.WsMessage{Type: service.MsgPing, ID: "p1"})
+		if ev := nextEvent(t, c); ev.Type != service.EventPong {
+			t.Fatalf("got %+v, want no judged event after the stop", ev)
+		}
+	}
+}
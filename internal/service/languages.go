@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/catalog"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+)
+
+// languagePool holds the executor pool of every version of one language.
+type languagePool struct {
+	versions       map[string]*Compiler
+	displayNames   map[string]string
+	defaultVersion string
+}
+
+// backends returns the backends of all versions of the language.
+func (p *languagePool) backends() []*backend {
+	var backends []*backend
+	for _, compiler := range p.versions {
+		backends = append(backends, compiler.backends...)
+	}
+	return backends
+}
+
+// LanguageStatus describes the versions of a registered language and whether they accept executions.
+type LanguageStatus struct {
+	Available      bool
+	DefaultVersion string
+	Versions       []VersionStatus
+}
+
+// VersionStatus describes one version of a registered language.
+type VersionStatus struct {
+	Version     string
+	DisplayName string
+	Available   bool
+}
+
+// reloadExecutors swaps the executors map for one built from the registry snapshot.
+// Running executions keep the backend they were started on, and the active session
+// counters of backends that are still present carry over to the new pools.
+func (s *Service) reloadExecutors(entries []repos.Executor) {
+	s.executorsMu.Lock()
+	previous := s.executors
+	executors := make(map[string]*languagePool)
+	for _, entry := range entries {
+		entry.Language = catalog.Resolve(entry.Language)
+		pool, ok := executors[entry.Language]
+		if !ok {
+			pool = &languagePool{
+				versions:     make(map[string]*Compiler),
+				displayNames: make(map[string]string),
+			}
+			executors[entry.Language] = pool
+		}
+
+		var old *Compiler
+		if prev, ok := previous[entry.Language]; ok {
+			old = prev.versions[entry.Version]
+		}
+		pool.versions[entry.Version] = newCompiler(entry, old, s.cfg)
+		pool.displayNames[entry.Version] = entry.DisplayName
+		if entry.Default && pool.defaultVersion == "" {
+			pool.defaultVersion = entry.Version
+		}
+	}
+	for _, pool := range executors {
+		if pool.defaultVersion == "" {
+			pool.defaultVersion = sortVersions(pool.versions)[0]
+		}
+	}
+	s.executors = executors
+	s.executorsMu.Unlock()
+
+	s.logger.Info("Reloaded language executors", map[string]any{"languages": len(executors), "executors": len(entries)})
+}
+
+// executor looks up the executor of the given language version, accepting any alias of the
+// language and falling back to its default version when version is empty. It returns the resolved version.
+func (s *Service) executor(language, version string) (CodeExecutor, string, error) {
+	s.executorsMu.RLock()
+	defer s.executorsMu.RUnlock()
+
+	pool, ok := s.executors[catalog.Resolve(language)]
+	if !ok {
+		return nil, "", fmt.Errorf("Language '%s' is not supported", language)
+	}
+	if version == "" {
+		version = pool.defaultVersion
+	}
+	compiler, ok := pool.versions[version]
+	if !ok {
+		return nil, "", fmt.Errorf("Version '%s' of language '%s' is not supported", version, language)
+	}
+	return compiler, version, nil
+}
+
+// Languages reports the versions of every registered language and their availability.
+func (s *Service) Languages() map[string]LanguageStatus {
+	s.executorsMu.RLock()
+	defer s.executorsMu.RUnlock()
+
+	languages := make(map[string]LanguageStatus, len(s.executors))
+	for language, pool := range s.executors {
+		status := LanguageStatus{DefaultVersion: pool.defaultVersion}
+		for _, version := range sortVersions(pool.versions) {
+			available := pool.versions[version].available()
+			status.Available = status.Available || available
+			status.Versions = append(status.Versions, VersionStatus{
+				Version:     version,
+				DisplayName: pool.displayNames[version],
+				Available:   available,
+			})
+		}
+		languages[language] = status
+	}
+	return languages
+}
+
+// sortVersions returns the version keys ordered from newest to oldest.
+func sortVersions(versions map[string]*Compiler) []string {
+	keys := make([]string, 0, len(versions))
+	for version := range versions {
+		keys = append(keys, version)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return compareVersions(b, a)
+	})
+	return keys
+}
+
+// compareVersions compares dotted versions numerically segment by segment ("3.12" > "3.8"),
+// falling back to string comparison for non-numeric segments.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		if aErr == nil && bErr == nil {
+			if an != bn {
+				return an - bn
+			}
+			continue
+		}
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func limitedGateway(t *testing.T, exec *fakeexec.Server, outputBytes, outputLines map[string]int) *wstest.Client {
+	t.Helper()
+	cfg := testutil.Config()
+	cfg.RunCfg.OutputBytes = outputBytes
+	cfg.RunCfg.OutputLines = outputLines
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	return wstest.Dial(t, gw.ExecuteURL(), nil)
+}
+
+func TestTypedProtocol_OutputByteLimit(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("hello world\n"),
+		fakeexec.Output("more\n"),
+		fakeexec.Hang(),
+	)
+	c := limitedGateway(t, exec, map[string]int{"*": 10}, nil)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "hello worl" {
+		t.Fatalf("got output %q, want it cut at 10 bytes", ev.Output)
+	}
+	ev := expectEvent(t, c, service.EventTruncated, "r1")
+	want := service.LimitInfo{Name: service.LimitOutputBytes, Max: 10, OutputBytes: 10, OutputLines: 0}
+	if ev.Limit == nil || *ev.Limit != want {
+		t.Fatalf("got limit %+v, want %+v", ev.Limit, want)
+	}
+	exit := expectEvent(t, c, service.EventExit, "r1")
+	if exit.Reason != service.ExitTruncated || exit.ExitInfo == nil || exit.OutputBytes != 10 {
+		t.Fatalf("got exit %+v, want reason %s after 10 bytes", exit, service.ExitTruncated)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestTypedProtocol_OutputLineLimitPerLanguage(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("a\nb\nc\n"),
+		fakeexec.Hang(),
+	)
+	c := limitedGateway(t, exec, nil, map[string]int{"*": 1, "python": 2})
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "py", Code: "print(1)"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "a\nb\n" {
+		t.Fatalf("got output %q, want the first 2 lines", ev.Output)
+	}
+	if ev := expectEvent(t, c, service.EventTruncated, "r1"); ev.Limit == nil || ev.Limit.Name != service.LimitOutputLines || ev.Limit.OutputLines != 2 {
+		t.Fatalf("got limit %+v, want %s after 2 lines", ev.Limit, service.LimitOutputLines)
+	}
+	if exit := expectEvent(t, c, service.EventExit, "r1"); exit.Reason != service.ExitTruncated || exit.OutputLines != 2 {
+		t.Fatalf("got exit %+v, want reason %s after 2 lines", exit, service.ExitTruncated)
+	}
+}
+
+func TestExecuteWithWs_LegacyOutputLimit(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("hello world\n"),
+		fakeexec.Hang(),
+	)
+	c := limitedGateway(t, exec, map[string]int{"*": 5}, nil)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "hello")
+	expect(t, c, "TRUNCATED", "5 bytes")
+	if resp := expect(t, c, "STREAM_CLOSED", ""); resp.ExitInfo == nil || resp.OutputBytes != 5 {
+		t.Fatalf("got %+v, want exit info with 5 output bytes", resp)
+	}
+}
+
+func timedGateway(t *testing.T, exec *fakeexec.Server, wall, idle time.Duration) *wstest.Client {
+	t.Helper()
+	cfg := testutil.Config()
+	cfg.RunCfg.WallTime = map[string]time.Duration{"*": wall}
+	cfg.RunCfg.IdleTime = map[string]time.Duration{"*": idle}
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	return wstest.Dial(t, gw.ExecuteURL(), nil)
+}
+
+func TestTypedProtocol_WallTimeout(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("working\n"),
+		fakeexec.Hang(),
+	)
+	c := timedGateway(t, exec, 50*time.Millisecond, 0)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	expectEvent(t, c, service.EventOutput, "r1")
+	ev := expectEvent(t, c, service.EventTimeout, "r1")
+	want := service.LimitInfo{Name: service.LimitWallTime, Max: 50, OutputBytes: 8, OutputLines: 1}
+	if ev.Limit == nil || *ev.Limit != want {
+		t.Fatalf("got limit %+v, want %+v", ev.Limit, want)
+	}
+	if exit := expectEvent(t, c, service.EventExit, "r1"); exit.Reason != service.ExitTimeout || exit.WallTimeMs < 50 {
+		t.Fatalf("got exit %+v, want reason %s after 50ms", exit, service.ExitTimeout)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestTypedProtocol_IdleTimeoutRestartsOnInput(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.AwaitInput(),
+		fakeexec.AwaitInput(),
+		fakeexec.EchoInput("got "),
+		fakeexec.Hang(),
+	)
+	c := timedGateway(t, exec, 0, 150*time.Millisecond)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(input())"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	time.Sleep(100 * time.Millisecond)
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: "a"})
+	time.Sleep(100 * time.Millisecond)
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i2", Input: "b"})
+
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "got b" {
+		t.Fatalf("got output %q, want %q", ev.Output, "got b")
+	}
+	if ev := expectEvent(t, c, service.EventTimeout, "r1"); ev.Limit == nil || ev.Limit.Name != service.LimitIdleTime {
+		t.Fatalf("got limit %+v, want %s", ev.Limit, service.LimitIdleTime)
+	}
+	expectEvent(t, c, service.EventExit, "r1")
+}
+
+func TestExecuteWithWs_LegacyTimeout(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	c := timedGateway(t, exec, 50*time.Millisecond, 0)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "TIMEOUT", "time limit of 50ms")
+	expect(t, c, "STREAM_CLOSED", "")
+}
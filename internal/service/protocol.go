@@ -0,0 +1,266 @@
+package service
+
+// ProtocolVersion is the version of the typed WebSocket protocol spoken by the gateway.
+// A client opts into the typed protocol by sending a message with a type; until then
+// the connection speaks the legacy format of WsMessage without type and WsResponse.
+const ProtocolVersion = 1
+
+// Client message types of the typed protocol.
+const (
+	MsgRun    = "run"
+	MsgInput  = "input"
+	MsgStop   = "stop"
+	MsgPing   = "ping"
+	MsgEOF    = "eof"
+	MsgResume = "resume"
+	MsgJudge  = "judge"
+)
+
+// Server event types of the typed protocol.
+const (
+	EventOutput    = "output"
+	EventError     = "error"
+	EventStatus    = "status"
+	EventExit      = "exit"
+	EventQueued    = "queued"
+	EventPong      = "pong"
+	EventResumed   = "resumed"
+	EventTruncated = "truncated"
+	EventTimeout   = "timeout"
+	EventVerdict   = "verdict"
+	EventJudged    = "judged"
+)
+
+// Verdicts on the test cases of a judge message.
+const (
+	VerdictAccepted     = "AC"
+	VerdictWrongAnswer  = "WA"
+	VerdictRuntimeError = "RE"
+	VerdictTimeLimit    = "TLE"
+	VerdictOutputLimit  = "OLE"
+	// VerdictInternalError means the gateway could not run the test case, e.g. no executor was available.
+	VerdictInternalError = "IE"
+)
+
+// StateExitCode prefixes the executor status that reports the program's exit code, e.g. "EXIT_CODE:1".
+// Only the local executor sends it: the protocol of the remote executors has no exit code, so runs on
+// them end without one. Exit codes are reported when known but never judged.
+const StateExitCode = "EXIT_CODE:"
+
+// Streams tagging output and error events: the program's stdout and stderr, and the gateway itself.
+const (
+	StreamStdout  = "stdout"
+	StreamStderr  = "stderr"
+	StreamGateway = "gateway"
+)
+
+// Reasons reported by exit events.
+const (
+	ExitCompleted = "completed"
+	ExitCancelled = "cancelled"
+	ExitFailed    = "failed"
+	ExitStopped   = "stopped"
+	ExitTruncated = "truncated"
+	ExitTimeout   = "timeout"
+	// ExitReplaced ends the run of a legacy client that submitted new code; it is not reported to legacy clients.
+	ExitReplaced = "replaced"
+)
+
+// Limits reported by truncated and timeout events.
+const (
+	LimitOutputBytes = "output_bytes"
+	LimitOutputLines = "output_lines"
+	LimitWallTime    = "wall_time_ms"
+	LimitIdleTime    = "idle_time_ms"
+)
+
+// Error codes carried by WsError.
+const (
+	ErrInvalidMessage      = "INVALID_MESSAGE"
+	ErrUnsupportedProtocol = "UNSUPPORTED_PROTOCOL"
+	ErrUnsupportedLanguage = "UNSUPPORTED_LANGUAGE"
+	ErrDangerousCode       = "DANGEROUS_CODE"
+	ErrExecutorUnavailable = "EXECUTOR_UNAVAILABLE"
+	ErrNoActiveRun         = "NO_ACTIVE_RUN"
+	ErrAmbiguousRun        = "AMBIGUOUS_RUN"
+	ErrTooManyRuns         = "TOO_MANY_RUNS"
+	ErrInputFailed         = "INPUT_FAILED"
+	ErrInputClosed         = "INPUT_CLOSED"
+	ErrStreamFailed        = "STREAM_FAILED"
+	ErrConnection          = "CONNECTION_ERROR"
+	ErrSessionNotFound     = "SESSION_NOT_FOUND"
+	ErrEventsLost          = "EVENTS_LOST"
+	ErrEventsDropped       = "EVENTS_DROPPED"
+	ErrJobNotFound         = "JOB_NOT_FOUND"
+	ErrJobAbandoned        = "JOB_ABANDONED"
+	ErrQueueUnavailable    = "QUEUE_UNAVAILABLE"
+)
+
+// WsMessage represents the JSON payload received over WebSocket.
+// Typed messages set Type (and optionally V and ID); legacy messages leave them empty
+// and their intent is inferred from the fields that are set.
+// Stdin is sent to the program right after the code of a run, and EOF on a run or input
+// message closes the program's stdin once the message was delivered. RunID optionally names
+// a new run, and selects the run addressed by input, eof and stop messages. A resume message
+// attaches the connection to the session SessionID and replays its events after LastSeq.
+// A judge message runs the code against each of its Tests within TimeLimitMs.
+type WsMessage struct {
+	V         int    `json:"v,omitempty"`
+	Type      string `json:"type,omitempty"`
+	ID        string `json:"id,omitempty"`
+	RunID     string `json:"run_id,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Stdin     string `json:"stdin,omitempty"`
+	Input     string `json:"input,omitempty"`
+	EOF       bool   `json:"eof,omitempty"`
+	Stop      bool   `json:"stop,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	LastSeq   uint64 `json:"last_seq,omitempty"`
+
+	Tests       []TestCase `json:"tests,omitempty"`
+	TimeLimitMs int64      `json:"time_limit_ms,omitempty"`
+}
+
+// TestCase is an input of a judge message and the output expected for it.
+type TestCase struct {
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+}
+
+// WsResponse represents the JSON response sent over WebSocket in the legacy protocol.
+// Output of the program and errors carry the Stream they come from, and the final
+// STREAM_CLOSED response carries the ExitInfo of the run.
+type WsResponse struct {
+	Output string   `json:"output"`
+	Status string   `json:"status"`
+	Stream string   `json:"stream,omitempty"`
+	Error  *WsError `json:"error,omitempty"`
+	*ExitInfo
+}
+
+// WsEvent is a server event of the typed protocol. ID echoes the id of the client message
+// the event answers, or of the run message for events produced by a run, which are also tagged with its RunID.
+// Output events carry the Stream they were written to, error events raised by the gateway
+// are tagged StreamGateway, and exit events carry the ExitInfo of the run.
+// Seq numbers the events of a session; queued and resumed events carry the SessionID to resume.
+// Truncated and timeout events carry the Limit that made the gateway stop the run.
+// Verdict events carry the Verdict on one test case of a judge message, and the judged event its Judge result.
+type WsEvent struct {
+	V         int        `json:"v"`
+	Seq       uint64     `json:"seq,omitempty"`
+	SessionID string     `json:"session_id,omitempty"`
+	Type      string     `json:"type"`
+	ID        string     `json:"id,omitempty"`
+	RunID     string     `json:"run_id,omitempty"`
+	Stream    string     `json:"stream,omitempty"`
+	Output    string     `json:"output,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Error     *WsError   `json:"error,omitempty"`
+	Limit     *LimitInfo `json:"limit,omitempty"`
+	Verdict   *Verdict   `json:"verdict,omitempty"`
+	Judge     *Judgement `json:"judge,omitempty"`
+	*ExitInfo
+}
+
+// ExitInfo describes how a run ended. ExitCode is only known when the executor reported it, which
+// the remote executors never do (see StateExitCode),
+// and TTFBMs only when the program produced output. OutputBytes and OutputLines count the
+// output delivered to the client, which stops at the output limits of the language.
+type ExitInfo struct {
+	ExitCode    *int   `json:"exit_code,omitempty"`
+	WallTimeMs  int64  `json:"wall_time_ms"`
+	TTFBMs      *int64 `json:"ttfb_ms,omitempty"`
+	OutputBytes int64  `json:"output_bytes"`
+	OutputLines int64  `json:"output_lines"`
+}
+
+// LimitInfo names a limit a run exceeded, its Max value and the output delivered until then.
+type LimitInfo struct {
+	Name        string `json:"name"`
+	Max         int64  `json:"max"`
+	OutputBytes int64  `json:"output_bytes"`
+	OutputLines int64  `json:"output_lines"`
+}
+
+// Verdict is the verdict on the test case Test, the index of the case in the judge message.
+// TimeMs is the wall time of its execution. Reason is the exit reason of the execution and Error
+// tells why the gateway could not run it.
+type Verdict struct {
+	Test     int      `json:"test"`
+	Verdict  string   `json:"verdict"`
+	TimeMs   int64    `json:"time_ms"`
+	ExitCode *int     `json:"exit_code,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr,omitempty"`
+	Error    *WsError `json:"error,omitempty"`
+}
+
+// Judgement sums up a judge message: its Verdict is AC when every test case passed and otherwise
+// the verdict of the first one that did not. TimeMs is the wall time of the whole judging.
+type Judgement struct {
+	Verdict string    `json:"verdict"`
+	Passed  int       `json:"passed"`
+	Total   int       `json:"total"`
+	TimeMs  int64     `json:"time_ms"`
+	Tests   []Verdict `json:"tests"`
+}
+
+// WsError carries machine readable details of a failed request.
+type WsError struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// errorEvent builds an error event raised by the gateway itself.
+func errorEvent(id, code, message string) WsEvent {
+	return WsEvent{Type: EventError, ID: id, Stream: StreamGateway, Error: &WsError{Code: code, Message: message}}
+}
+
+// legacyResponses translates a typed event into the responses a legacy client expects.
+// Events that have no legacy counterpart translate to nothing.
+func legacyResponses(ev WsEvent) []WsResponse {
+	switch ev.Type {
+	case EventOutput:
+		status := ev.Status
+		switch {
+		case ev.Stream == StreamStderr:
+			status = "ERROR"
+		case status == "":
+			status = "SUCCESS"
+		}
+		return []WsResponse{{Output: ev.Output, Status: status, Stream: ev.Stream}}
+	case EventError:
+		return []WsResponse{{Output: ev.Error.Message, Status: "ERROR", Stream: ev.Stream, Error: ev.Error}}
+	case EventStatus:
+		output := ev.Output
+		if output == "" {
+			output = ev.Status
+		}
+		return []WsResponse{{Output: output, Status: ev.Status}}
+	case EventTruncated:
+		return []WsResponse{{Output: ev.Output, Status: "TRUNCATED", Stream: ev.Stream}}
+	case EventTimeout:
+		return []WsResponse{{Output: ev.Output, Status: "TIMEOUT", Stream: ev.Stream}}
+	case EventExit:
+		closed := WsResponse{Output: "Execution stream closed", Status: "STREAM_CLOSED", ExitInfo: ev.ExitInfo}
+		switch ev.Reason {
+		case ExitCompleted:
+			return []WsResponse{{Output: "Execution stream closed by server", Status: "INFO"}, closed}
+		case ExitCancelled:
+			return []WsResponse{{Output: "Stream cancelled", Status: "ERROR"}, closed}
+		case ExitStopped:
+			return []WsResponse{{Output: "Execution stopped", Status: "STOPPED"}, closed}
+		case ExitReplaced:
+			return nil
+		default:
+			return []WsResponse{closed}
+		}
+	default:
+		return nil
+	}
+}
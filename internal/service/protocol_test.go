@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func TestTypedProtocol_RunEchoesRequestID(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("hello\n"),
+		fakeexec.Status("EXECUTION_COMPLETE"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{V: 1, Type: service.MsgRun, ID: "r1", Language: "python", Code: `print("hello")`})
+	expectEvent(t, c, service.EventQueued, "r1")
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "hello\n" {
+		t.Fatalf("got output %q", ev.Output)
+	}
+	// typed clients see every status, including the ones hidden from legacy clients
+	if ev := expectEvent(t, c, service.EventStatus, "r1"); ev.Status != "EXECUTION_COMPLETE" {
+		t.Fatalf("got status %q", ev.Status)
+	}
+	if ev := expectEvent(t, c, service.EventExit, "r1"); ev.Reason != service.ExitCompleted {
+		t.Fatalf("got exit reason %q, want %q", ev.Reason, service.ExitCompleted)
+	}
+}
+
+func TestTypedProtocol_EmptyInput(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.AwaitInput(),
+		fakeexec.EchoInput("got ["),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "input()"})
+	expectEvent(t, c, service.EventQueued, "r1")
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: ""})
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "got [" {
+		t.Fatalf("got output %q", ev.Output)
+	}
+	expectEvent(t, c, service.EventExit, "r1")
+
+	reqs := exec.Requests()
+	if len(reqs) != 2 || reqs[1].GetInput() == nil || reqs[1].GetInput().GetInputText() != "" {
+		t.Fatalf("executor received %v, want code followed by an empty input", reqs)
+	}
+}
+
+func TestTypedProtocol_ControlMessages(t *testing.T) {
+	c := newGateway(t, fakeexec.NewServer(t))
+
+	c.Send(service.WsMessage{Type: service.MsgPing, ID: "p1"})
+	expectEvent(t, c, service.EventPong, "p1")
+
+	c.Send(service.WsMessage{V: service.ProtocolVersion + 1, Type: service.MsgPing, ID: "p2"})
+	if ev := expectEvent(t, c, service.EventError, "p2"); ev.Error.Code != service.ErrUnsupportedProtocol {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrUnsupportedProtocol)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: "x"})
+	if ev := expectEvent(t, c, service.EventError, "i1"); ev.Error.Code != service.ErrNoActiveRun {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrNoActiveRun)
+	}
+
+	c.Send(service.WsMessage{Type: "compile", ID: "x1"})
+	if ev := expectEvent(t, c, service.EventError, "x1"); ev.Error.Code != service.ErrInvalidMessage {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrInvalidMessage)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "cobol", Code: "DISPLAY 1"})
+	if ev := expectEvent(t, c, service.EventError, "r1"); ev.Error.Code != service.ErrUnsupportedLanguage {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrUnsupportedLanguage)
+	}
+}
+
+func TestTypedProtocol_StopRun(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("looping"), fakeexec.Hang())
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "while True: print('looping')"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	expectEvent(t, c, service.EventOutput, "r1")
+
+	c.Send(service.WsMessage{Type: service.MsgStop, ID: "s1"})
+	if ev := expectEvent(t, c, service.EventExit, "r1"); ev.Reason != service.ExitStopped {
+		t.Fatalf("got exit reason %q, want %q", ev.Reason, service.ExitStopped)
+	}
+	exec.WaitIdle(wstest.DefaultTimeout)
+
+	// the connection accepts a new submission
+	exec.SetScript(fakeexec.Output("again"))
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r2", Language: "python", Code: "print('again')"})
+	expectEvent(t, c, service.EventQueued, "r2")
+	expectEvent(t, c, service.EventOutput, "r2")
+	expectEvent(t, c, service.EventExit, "r2")
+
+	c.Send(service.WsMessage{Type: service.MsgStop, ID: "s2"})
+	if ev := expectEvent(t, c, service.EventError, "s2"); ev.Error.Code != service.ErrNoActiveRun {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrNoActiveRun)
+	}
+}
+
+func TestTypedProtocol_StreamsAndExitInfo(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Delay(20*time.Millisecond),
+		fakeexec.Output("out\n"),
+		fakeexec.Stderr("warn\n"),
+		fakeexec.Status(service.StateExitCode+"1"),
+		fakeexec.Status("EXECUTION_COMPLETE"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Stream != service.StreamStdout || ev.Output != "out\n" {
+		t.Fatalf("got %+v, want stdout output", ev)
+	}
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Stream != service.StreamStderr || ev.Output != "warn\n" {
+		t.Fatalf("got %+v, want stderr output", ev)
+	}
+	expectEvent(t, c, service.EventStatus, "r1")
+	expectEvent(t, c, service.EventStatus, "r1")
+
+	ev := expectEvent(t, c, service.EventExit, "r1")
+	if ev.ExitInfo == nil || ev.ExitCode == nil || *ev.ExitCode != 1 {
+		t.Fatalf("got exit %+v, want exit code 1", ev.ExitInfo)
+	}
+	if ev.TTFBMs == nil || *ev.TTFBMs < 20 || ev.WallTimeMs < *ev.TTFBMs {
+		t.Fatalf("got wall time %dms and ttfb %v, want ttfb of at least 20ms within the wall time", ev.WallTimeMs, ev.TTFBMs)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r2", Language: "cobol", Code: "DISPLAY 1"})
+	if ev := expectEvent(t, c, service.EventError, "r2"); ev.Stream != service.StreamGateway {
+		t.Fatalf("got %+v, want a gateway error", ev)
+	}
+}
+
+func TestTypedProtocol_StdinEOF(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.EchoInput("read: "))
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(sys.stdin.read())"})
+	expectEvent(t, c, service.EventQueued, "r1")
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: "a\n"})
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i2", Input: "b\n"})
+	c.Send(service.WsMessage{Type: service.MsgEOF, ID: "e1"})
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "read: a\nb\n" {
+		t.Fatalf("got output %q", ev.Output)
+	}
+	expectEvent(t, c, service.EventExit, "r1")
+}
+
+func TestTypedProtocol_RunWithStdinAndAutoEOF(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.EchoInput("read: "))
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(sys.stdin.read())", Stdin: "1 2\n", EOF: true})
+	expectEvent(t, c, service.EventQueued, "r1")
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "read: 1 2\n" {
+		t.Fatalf("got output %q", ev.Output)
+	}
+	expectEvent(t, c, service.EventExit, "r1")
+}
+
+func TestTypedProtocol_InputAfterEOF(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.Hang())
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)", EOF: true})
+	expectEvent(t, c, service.EventQueued, "r1")
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: "late"})
+	if ev := expectEvent(t, c, service.EventError, "i1"); ev.Error.Code != service.ErrInputClosed {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrInputClosed)
+	}
+}
+
+func TestTypedProtocol_ConcurrentRuns(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScriptFunc(func(code *compiler_service.Code) []fakeexec.Step {
+		return []fakeexec.Step{fakeexec.AwaitInput(), fakeexec.EchoInput(code.GetSourceCode() + " got ")}
+	})
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "1", RunID: "tests", Language: "python", Code: "tests"})
+	if ev := expectEvent(t, c, service.EventQueued, "1"); ev.RunID != "tests" {
+		t.Fatalf("got run id %q, want the requested one", ev.RunID)
+	}
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "2", Language: "python", Code: "main"})
+	main := expectEvent(t, c, service.EventQueued, "2").RunID
+	if main == "" || main == "tests" {
+		t.Fatalf("got generated run id %q", main)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "3", RunID: "tests", Language: "python", Code: "again"})
+	if ev := expectEvent(t, c, service.EventError, "3"); ev.Error.Code != service.ErrInvalidMessage {
+		t.Fatalf("got error %+v for a duplicate run id", ev.Error)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "4", Input: "x"})
+	if ev := expectEvent(t, c, service.EventError, "4"); ev.Error.Code != service.ErrAmbiguousRun {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrAmbiguousRun)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "5", RunID: main, Input: "x"})
+	if ev := expectEvent(t, c, service.EventOutput, "2"); ev.RunID != main || ev.Output != "main got x" {
+		t.Fatalf("got %+v, want output of run %s", ev, main)
+	}
+	expectEvent(t, c, service.EventExit, "2")
+
+	// with a single active run the run id may be omitted
+	c.Send(service.WsMessage{Type: service.MsgStop, ID: "6"})
+	if ev := expectEvent(t, c, service.EventExit, "1"); ev.RunID != "tests" || ev.Reason != service.ExitStopped {
+		t.Fatalf("got %+v, want run tests stopped", ev)
+	}
+
+	c.Send(service.WsMessage{Type: service.MsgStop, ID: "7", RunID: "tests"})
+	if ev := expectEvent(t, c, service.EventError, "7"); ev.Error.Code != service.ErrNoActiveRun {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrNoActiveRun)
+	}
+}
+
+func TestTypedProtocol_RunLimit(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	cfg := testutil.Config()
+	cfg.WsCfg.MaxRuns = 1
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	c := wstest.Dial(t, gw.ExecuteURL(), nil)
+
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "1", Language: "python", Code: "print(1)"})
+	expectEvent(t, c, service.EventQueued, "1")
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "2", Language: "python", Code: "print(2)"})
+	if ev := expectEvent(t, c, service.EventError, "2"); ev.Error.Code != service.ErrTooManyRuns {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrTooManyRuns)
+	}
+}
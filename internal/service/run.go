@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+)
+
+// run is one execution started by a run message. Several runs may be active on one connection;
+// runID tells them apart in client messages and events.
+type run struct {
+	id        string
+	runID     string
+	sessionID string
+	stream    compiler_service.CodeExecutor_ExecuteClient
+	cancel    context.CancelFunc
+	started   time.Time
+
+	// output limits of the run's language, 0 for no limit
+	maxBytes int64
+	maxLines int64
+
+	// time limits of the run's language, armed by startTimers
+	wallTimer *time.Timer
+	idleTimer *time.Timer
+	idleTime  time.Duration
+
+	// only accessed while handling client messages, under the session's handleMu
+	inputClosed bool
+
+	// only accessed by the goroutine forwarding the stream
+	firstByte   time.Duration
+	exitCode    *int
+	outputBytes int64
+	outputLines int64
+	truncated   bool
+
+	// serialises Send and CloseSend, which the stream does not allow concurrently
+	sendMu sync.Mutex
+
+	mu      sync.Mutex
+	reason  string
+	timeout *LimitInfo
+}
+
+// exitInfo reports the exit code and timings of the run once its stream has ended.
+func (r *run) exitInfo() *ExitInfo {
+	info := &ExitInfo{
+		ExitCode:    r.exitCode,
+		WallTimeMs:  time.Since(r.started).Milliseconds(),
+		OutputBytes: r.outputBytes,
+		OutputLines: r.outputLines,
+	}
+	if r.firstByte > 0 {
+		ttfb := r.firstByte.Milliseconds()
+		info.TTFBMs = &ttfb
+	}
+	return info
+}
+
+// observe records the time to first byte and the exit code reported by the executor.
+func (r *run) observe(resp *compiler_service.ExecuteResponse) {
+	switch payload := resp.Payload.(type) {
+	case *compiler_service.ExecuteResponse_Output, *compiler_service.ExecuteResponse_Error:
+		if r.firstByte == 0 {
+			r.firstByte = max(time.Since(r.started), time.Nanosecond)
+		}
+	case *compiler_service.ExecuteResponse_Status:
+		if code, ok := strings.CutPrefix(payload.Status.State, StateExitCode); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+				r.exitCode = &n
+			}
+		}
+	}
+}
+
+// clip counts the output text against the output limits of the run and returns the part of it
+// that fits. When text does not fit entirely, the run is marked truncated and clip also returns
+// the limit that was exceeded.
+func (r *run) clip(text string) (string, *LimitInfo) {
+	var limit *LimitInfo
+	if r.maxBytes > 0 && r.outputBytes+int64(len(text)) > r.maxBytes {
+		n := int(r.maxBytes - r.outputBytes)
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+		limit = &LimitInfo{Name: LimitOutputBytes, Max: r.maxBytes}
+	}
+	if r.maxLines > 0 {
+		remaining := r.maxLines - r.outputLines
+		end := 0
+		for ; remaining > 0 && end < len(text); remaining-- {
+			i := strings.IndexByte(text[end:], '\n')
+			if i < 0 {
+				end = len(text)
+				break
+			}
+			end += i + 1
+		}
+		if end < len(text) {
+			text = text[:end]
+			limit = &LimitInfo{Name: LimitOutputLines, Max: r.maxLines}
+		}
+	}
+
+	r.outputBytes += int64(len(text))
+	r.outputLines += int64(strings.Count(text, "\n"))
+	if limit != nil {
+		r.truncated = true
+		limit.OutputBytes, limit.OutputLines = r.outputBytes, r.outputLines
+	}
+	return text, limit
+}
+
+// stop ends the run on behalf of the gateway, recording the reason reported by its exit event.
+// The stream is half-closed before it is cancelled so that the executor sees the end of input
+// and then the cancellation of the call, upon which it terminates the program.
+func (r *run) stop(reason string) {
+	r.mu.Lock()
+	if r.reason == "" {
+		r.reason = reason
+	}
+	r.mu.Unlock()
+
+	r.closeSend()
+	r.cancel()
+}
+
+// startTimers arms the wall-clock limit, counted from the submission, and the idle limit of the run
+// until ctx ends; a zero limit is not enforced.
+func (r *run) startTimers(ctx context.Context, wall, idle time.Duration) {
+	if wall > 0 {
+		r.wallTimer = time.AfterFunc(wall-time.Since(r.started), func() { r.expire(LimitWallTime, wall) })
+	}
+	if idle > 0 {
+		r.idleTime = idle
+		r.idleTimer = time.AfterFunc(idle, func() { r.expire(LimitIdleTime, idle) })
+	}
+	context.AfterFunc(ctx, func() {
+		if r.wallTimer != nil {
+			r.wallTimer.Stop()
+		}
+		if r.idleTimer != nil {
+			r.idleTimer.Stop()
+		}
+	})
+}
+
+// touch restarts the idle limit after the program wrote output or the client sent input.
+func (r *run) touch() {
+	if r.idleTimer != nil {
+		r.idleTimer.Reset(r.idleTime)
+	}
+}
+
+// expire stops the run for exceeding the time limit name.
+func (r *run) expire(name string, limit time.Duration) {
+	r.mu.Lock()
+	if r.reason == "" {
+		r.timeout = &LimitInfo{Name: name, Max: limit.Milliseconds()}
+	}
+	r.mu.Unlock()
+	r.stop(ExitTimeout)
+}
+
+// timeoutLimit returns the time limit the run exceeded, with the output delivered until then.
+// It must only be called by the goroutine forwarding the stream.
+func (r *run) timeoutLimit() *LimitInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timeout == nil {
+		return nil
+	}
+	limit := *r.timeout
+	limit.OutputBytes, limit.OutputLines = r.outputBytes, r.outputLines
+	return &limit
+}
+
+// send sends req on the stream of the run.
+func (r *run) send(req *compiler_service.ExecuteRequest) error {
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+	return r.stream.Send(req)
+}
+
+// closeSend half-closes the stream of the run, which closes the program's stdin.
+func (r *run) closeSend() error {
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+	return r.stream.CloseSend()
+}
+
+// errorEvent builds an error event about the run raised by the gateway.
+func (r *run) errorEvent(id, code, message string) WsEvent {
+	ev := errorEvent(id, code, message)
+	ev.RunID = r.runID
+	return ev
+}
+
+// stopReason returns the reason passed to the first stop, or "" if the run was not stopped.
+func (r *run) stopReason() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reason
+}
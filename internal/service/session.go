@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// outbox is the connection a session sends its events to: a WebSocket or an SSE stream.
+type outbox interface {
+	// send queues v, a WsEvent or for legacy clients a WsResponse.
+	send(v any) error
+	// drop disconnects the client, which has been replaced by another connection.
+	drop()
+}
+
+// session holds the executions of one client. It is attached to the client's connection and,
+// for typed clients, survives the loss of that connection for its grace period: its runs keep
+// going and their events are buffered with sequence numbers until the client resumes the session
+// from a new connection, or the grace period ends and the runs are cancelled.
+type session struct {
+	s      *Service
+	id     string
+	grace  time.Duration
+	typed  atomic.Bool
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// serialises handle: the messages of a session come from the read loop of its connection but
+	// also from concurrent HTTP requests, such as the input and stop requests of a run started over HTTP
+	handleMu sync.Mutex
+
+	mu     sync.Mutex
+	out    outbox
+	runs   map[string]*run
+	seq    uint64
+	events []WsEvent
+	sent   uint64     // sequence number of the last event sent, which emit sends in order outside mu
+	turn   *sync.Cond // signalled on mu when sent or replaying change
+	// an attached connection is being sent the events it missed, which new events wait for
+	replaying bool
+	expiry    *time.Timer
+	closed    bool
+	calls     map[string]*WsError
+	judges    map[string]context.CancelFunc // cancels the judge messages being judged, by run id
+
+	// lowers the wall time limit of the session's runs, 0 to keep the limit of their language
+	wallTime time.Duration
+}
+
+// newSession registers a session attached to out, if not nil, that stays resumable for grace.
+// Its executions are not bound to ctx, which ends with the request, but only to the lifetime of the session.
+func (s *Service) newSession(ctx context.Context, out outbox, grace time.Duration) *session {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	sess := &session{
+		s:      s,
+		id:     uuid.NewString(),
+		grace:  grace,
+		ctx:    ctx,
+		cancel: cancel,
+		out:    out,
+		runs:   make(map[string]*run),
+		judges: make(map[string]context.CancelFunc),
+	}
+	sess.turn = sync.NewCond(&sess.mu)
+
+	s.sessionsMu.Lock()
+	s.sessions[sess.id] = sess
+	s.sessionsMu.Unlock()
+	return sess
+}
+
+// resume moves the connection out from current to the session requested by msg, replaying the events buffered
+// after msg.LastSeq. It returns the session the connection is attached to afterwards.
+func (s *Service) resume(current *session, out outbox, msg WsMessage) *session {
+	current.typed.Store(true)
+	if current.active() > 0 {
+		current.emit(errorEvent(msg.ID, ErrInvalidMessage, "A connection with active executions cannot resume another session"))
+		return current
+	}
+
+	s.sessionsMu.Lock()
+	sess, ok := s.sessions[msg.SessionID]
+	s.sessionsMu.Unlock()
+	if !ok || sess == current {
+		current.emit(errorEvent(msg.ID, ErrSessionNotFound, fmt.Sprintf("Session '%s' does not exist or has expired", msg.SessionID)))
+		return current
+	}
+
+	replay, ok := sess.attach(out, msg)
+	if !ok {
+		current.emit(errorEvent(msg.ID, ErrSessionNotFound, fmt.Sprintf("Session '%s' does not exist or has expired", msg.SessionID)))
+		return current
+	}
+	replay()
+	current.close()
+	s.logger.Info("Resumed WebSocket session", map[string]any{"session_id": sess.id, "last_seq": msg.LastSeq})
+	return sess
+}
+
+// attach makes out the connection of the session. A connection the session is still attached to
+// is closed, as the client evidently lost it. It returns false if the session has ended, and otherwise
+// a function replaying the buffered events the client has not seen and confirming with a resumed event.
+// New events of the session wait until the replay has run, which happens outside sess.mu as out
+// may only be read once attach has returned.
+func (sess *session) attach(out outbox, msg WsMessage) (func(), bool) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.closed {
+		return nil, false
+	}
+	if sess.out != nil && sess.out != out {
+		sess.out.drop()
+	}
+	// the events still being sent to the previous connection are replayed below
+	for sess.replaying || sess.sent < sess.seq {
+		sess.turn.Wait()
+	}
+	if sess.closed {
+		return nil, false
+	}
+	if sess.expiry != nil {
+		sess.expiry.Stop()
+		sess.expiry = nil
+	}
+	if sess.out != nil && sess.out != out {
+		sess.out.drop()
+	}
+	sess.out = out
+	sess.typed.Store(true)
+
+	var replay []WsEvent
+	if len(sess.events) > 0 && sess.events[0].Seq > msg.LastSeq+1 {
+		replay = append(replay, errorEvent(msg.ID, ErrEventsLost, fmt.Sprintf("%d event(s) after sequence number %d are no longer buffered", sess.events[0].Seq-msg.LastSeq-1, msg.LastSeq)))
+	}
+	for _, ev := range sess.events {
+		if ev.Seq > msg.LastSeq {
+			replay = append(replay, ev)
+		}
+	}
+	replay = append(replay, WsEvent{Type: EventResumed, ID: msg.ID, SessionID: sess.id})
+	sess.replaying = true
+
+	return func() {
+		var err error
+		for _, ev := range replay {
+			if err = sess.deliver(out, ev); err != nil {
+				break
+			}
+		}
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if err != nil && sess.out == out {
+			sess.lost(err)
+		}
+		sess.replaying = false
+		sess.turn.Broadcast()
+	}, true
+}
+
+// detach is called when the connection out ended with err. Typed sessions that were not closed normally
+// by the client stay resumable for the grace period, others end right away.
+func (sess *session) detach(out outbox, err error) {
+	grace := sess.grace
+
+	sess.mu.Lock()
+	if sess.out != out && sess.out != nil {
+		// the session was resumed from another connection
+		sess.mu.Unlock()
+		return
+	}
+	sess.out = nil
+	resumable := !sess.closed && sess.typed.Load() && grace > 0 && !websocket.IsCloseError(err, websocket.CloseNormalClosure)
+	if resumable {
+		sess.expiry = time.AfterFunc(grace, sess.close)
+	}
+	sess.mu.Unlock()
+
+	if resumable {
+		sess.s.logger.Info("Session detached, waiting for resume", map[string]any{"session_id": sess.id, "grace": grace.String()})
+		return
+	}
+	sess.close()
+}
+
+// close ends the session, cancelling its runs.
+func (sess *session) close() {
+	sess.mu.Lock()
+	if sess.closed {
+		sess.mu.Unlock()
+		return
+	}
+	sess.closed = true
+	sess.mu.Unlock()
+
+	sess.s.sessionsMu.Lock()
+	delete(sess.s.sessions, sess.id)
+	sess.s.sessionsMu.Unlock()
+
+	sess.cancel()
+	for _, r := range sess.snapshot() {
+		sess.finish(r)
+	}
+}
+
+// call handles msg on behalf of a request that expects an answer rather than events,
+// returning the error event msg raised, if any, besides the error returned by handle.
+func (sess *session) call(msg WsMessage) (*WsError, error) {
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
+	}
+	sess.mu.Lock()
+	if sess.calls == nil {
+		sess.calls = make(map[string]*WsError)
+	}
+	sess.calls[msg.ID] = nil
+	sess.mu.Unlock()
+
+	err := sess.handle(msg)
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	werr := sess.calls[msg.ID]
+	delete(sess.calls, msg.ID)
+	return werr, err
+}
+
+// active returns the number of active runs and judge messages being judged.
+func (sess *session) active() int {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return len(sess.runs) + len(sess.judges)
+}
+
+// target returns the run addressed by msg: the one named by its run id, or the only active run
+// when it names none. Otherwise it reports the problem to the client and returns nil.
+func (sess *session) target(msg WsMessage) *run {
+	runs := sess.snapshot()
+
+	if msg.RunID != "" {
+		for _, r := range runs {
+			if r.runID == msg.RunID {
+				return r
+			}
+		}
+		ev := errorEvent(msg.ID, ErrNoActiveRun, fmt.Sprintf("Run '%s' is not active", msg.RunID))
+		ev.RunID = msg.RunID
+		sess.emit(ev)
+		return nil
+	}
+
+	switch len(runs) {
+	case 0:
+		sess.emit(errorEvent(msg.ID, ErrNoActiveRun, "No active execution"))
+	case 1:
+		return runs[0]
+	default:
+		sess.emit(errorEvent(msg.ID, ErrAmbiguousRun, "Several executions are active, set 'run_id'"))
+	}
+	return nil
+}
+
+// snapshot returns the active runs.
+func (sess *session) snapshot() []*run {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	runs := make([]*run, 0, len(sess.runs))
+	for _, r := range sess.runs {
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+// finish cancels r and forgets it if it is still active.
+func (sess *session) finish(r *run) {
+	r.cancel()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.runs[r.runID] == r {
+		sess.s.logger.Info("Cleaning up current stream", map[string]any{"session_id": r.sessionID})
+		delete(sess.runs, r.runID)
+	}
+}
+
+// stopAll stops every active run with reason.
+func (sess *session) stopAll(reason string) {
+	for _, r := range sess.snapshot() {
+		r.stop(reason)
+		sess.finish(r)
+	}
+}
+
+// emit records ev in the session's replay buffer and sends it to the attached connection, if any.
+// Events are sent one at a time in sequence order, but without holding sess.mu so that a client
+// slow to read its events does not hold up its own messages, such as input or stop.
+func (sess *session) emit(ev WsEvent) {
+	sess.mu.Lock()
+	sess.seq++
+	ev.Seq = sess.seq
+	if werr, ok := sess.calls[ev.ID]; ok && werr == nil && ev.Type == EventError {
+		sess.calls[ev.ID] = ev.Error
+	}
+	if limit := sess.s.cfg.WsCfg.ResumeBuffer; limit > 0 && sess.resumable() {
+		if len(sess.events) >= limit {
+			sess.events = sess.events[1:]
+		}
+		sess.events = append(sess.events, ev)
+	}
+	for sess.replaying || sess.sent+1 < ev.Seq {
+		sess.turn.Wait()
+	}
+	out := sess.out
+	sess.mu.Unlock()
+
+	var err error
+	if out != nil {
+		err = sess.deliver(out, ev)
+	}
+
+	sess.mu.Lock()
+	if err != nil && sess.out == out {
+		sess.lost(err)
+	}
+	sess.sent = ev.Seq
+	sess.turn.Broadcast()
+	sess.mu.Unlock()
+}
+
+// resumable reports whether the session can be resumed from another connection, which legacy
+// sessions and those without grace period never are, so that only these buffer their events.
+// The caller must hold sess.mu.
+func (sess *session) resumable() bool {
+	return sess.typed.Load() && sess.grace > 0
+}
+
+// deliver queues ev on out in the protocol the client speaks.
+func (sess *session) deliver(out outbox, ev WsEvent) error {
+	if sess.typed.Load() {
+		ev.V = ProtocolVersion
+		return out.send(ev)
+	}
+	for _, resp := range legacyResponses(ev) {
+		if resp.Output == "WAITING_FOR_INPUT" || resp.Output == "EXECUTION_COMPLETE" {
+			continue
+		}
+		if err := out.send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lost drops the attached connection, which failed with err; its read loop notices and detaches
+// the session. The caller must hold sess.mu.
+func (sess *session) lost(err error) {
+	sess.s.logger.Error("Error writing to WebSocket", map[string]any{"session_id": sess.id, "error": err})
+	sess.out = nil
+}
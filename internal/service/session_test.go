@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+)
+
+func TestTypedProtocol_ResumeSession(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("before"), fakeexec.AwaitInput(), fakeexec.EchoInput("got "))
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	c := wstest.Dial(t, gw.ExecuteURL(), nil)
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(input())"})
+	queued := expectEvent(t, c, service.EventQueued, "r1")
+	if queued.SessionID == "" || queued.Seq == 0 {
+		t.Fatalf("got %+v, want session id and sequence number", queued)
+	}
+	expectEvent(t, c, service.EventOutput, "r1")
+	c.Drop()
+
+	c = wstest.Dial(t, gw.ExecuteURL(), nil)
+	c.Send(service.WsMessage{Type: service.MsgResume, ID: "x1", SessionID: queued.SessionID, LastSeq: queued.Seq})
+	// the output seen on the lost connection is replayed as it was not acknowledged
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "before" || ev.Seq != queued.Seq+1 {
+		t.Fatalf("got %+v, want replayed output after seq %d", ev, queued.Seq)
+	}
+	expectEvent(t, c, service.EventResumed, "x1")
+
+	c.Send(service.WsMessage{Type: service.MsgInput, ID: "i1", Input: "x"})
+	if ev := expectEvent(t, c, service.EventOutput, "r1"); ev.Output != "got x" {
+		t.Fatalf("got output %q", ev.Output)
+	}
+	expectEvent(t, c, service.EventExit, "r1")
+}
+
+func TestTypedProtocol_ResumeUnknownSession(t *testing.T) {
+	c := newGateway(t, fakeexec.NewServer(t))
+
+	c.Send(service.WsMessage{Type: service.MsgResume, ID: "x1", SessionID: "nope"})
+	if ev := expectEvent(t, c, service.EventError, "x1"); ev.Error.Code != service.ErrSessionNotFound {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrSessionNotFound)
+	}
+}
+
+func TestTypedProtocol_ResumeGraceExpires(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Hang())
+	cfg := testutil.Config()
+	cfg.WsCfg.ResumeGrace = 50 * time.Millisecond
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+
+	c := wstest.Dial(t, gw.ExecuteURL(), nil)
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	queued := expectEvent(t, c, service.EventQueued, "r1")
+	exec.WaitOpen(1, wstest.DefaultTimeout)
+	c.Drop()
+
+	exec.WaitIdle(wstest.DefaultTimeout)
+
+	c = wstest.Dial(t, gw.ExecuteURL(), nil)
+	c.Send(service.WsMessage{Type: service.MsgResume, ID: "x1", SessionID: queued.SessionID})
+	if ev := expectEvent(t, c, service.EventError, "x1"); ev.Error.Code != service.ErrSessionNotFound {
+		t.Fatalf("got error %+v, want %s", ev.Error, service.ErrSessionNotFound)
+	}
+}
+
+// floodGateway starts a gateway whose executor writes chunks of output as fast as it can.
+func floodGateway(t *testing.T, policy string, queue, chunks int) *wstest.Client {
+	t.Helper()
+	exec := fakeexec.NewServer(t)
+	steps := make([]fakeexec.Step, 0, chunks+1)
+	for i := range chunks {
+		steps = append(steps, fakeexec.Output(fmt.Sprintf("%04d%s\n", i, strings.Repeat("x", 4091))))
+	}
+	exec.SetScript(append(steps, fakeexec.Status("EXECUTION_COMPLETE"))...)
+
+	cfg := testutil.Config()
+	cfg.WsCfg.SlowClientPolicy = policy
+	cfg.WsCfg.QueueSize = queue
+	cfg.RunCfg.OutputBytes = map[string]int{}
+	cfg.RunCfg.OutputLines = map[string]int{}
+	gw := testutil.NewGateway(t, cfg, fakeexec.NewRegistry(fakeexec.Executor("python", exec)))
+	return wstest.Dial(t, gw.ExecuteURL(), nil)
+}
+
+func TestTypedProtocol_SlowClientBlock(t *testing.T) {
+	const chunks = 2000
+	c := floodGateway(t, service.PolicyBlock, 64, chunks)
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	time.Sleep(200 * time.Millisecond)
+
+	var output strings.Builder
+	events := 0
+	for {
+		ev := nextEvent(t, c)
+		if ev.Type == service.EventExit {
+			if ev.Reason != service.ExitCompleted {
+				t.Fatalf("got exit %+v, want %s", ev, service.ExitCompleted)
+			}
+			break
+		}
+		if ev.Type == service.EventOutput {
+			output.WriteString(ev.Output)
+			events++
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != chunks {
+		t.Fatalf("got %d lines of output, want %d", len(lines), chunks)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, fmt.Sprintf("%04d", i)) {
+			t.Fatalf("line %d is %.8q..., output is out of order", i, line)
+		}
+	}
+	if events >= chunks {
+		t.Fatalf("got %d output events for %d chunks, want queued output to be coalesced", events, chunks)
+	}
+}
+
+func TestTypedProtocol_SlowClientDrop(t *testing.T) {
+	c := floodGateway(t, service.PolicyDrop, 1, 2000)
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	time.Sleep(200 * time.Millisecond)
+
+	dropped := false
+	for {
+		ev := nextEvent(t, c)
+		if ev.Type == service.EventError && ev.Error.Code == service.ErrEventsDropped {
+			dropped = true
+		}
+		if ev.Type == service.EventExit {
+			break
+		}
+	}
+	if !dropped {
+		t.Fatalf("got no %s error, want the client to be told about dropped events", service.ErrEventsDropped)
+	}
+}
+
+func TestTypedProtocol_SlowClientDisconnect(t *testing.T) {
+	c := floodGateway(t, service.PolicyDisconnect, 1, 2000)
+	c.Send(service.WsMessage{Type: service.MsgRun, ID: "r1", Language: "python", Code: "print(1)"})
+	time.Sleep(200 * time.Millisecond)
+
+	for {
+		payload, err := c.TryNext(wstest.DefaultTimeout)
+		if err != nil {
+			return
+		}
+		var ev service.WsEvent
+		if err := json.Unmarshal(payload, &ev); err == nil && ev.Type == service.EventExit {
+			t.Fatalf("got exit %+v, want the slow client to be disconnected", ev)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// EventStream delivers the events of a run started over HTTP, as an alternative to the WebSocket
+// for clients whose proxies break upgrades. It is an outbox of the run's session, so it replays
+// the events it missed like a resumed WebSocket and the session outlives it for SSECfg.Grace.
+type EventStream struct {
+	sess   *session
+	events chan WsEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// StartRun starts the execution described by msg in a new session without a connection, whose
+// events are then read with Subscribe. It returns the session id and the run id, or the error
+// that prevented the run from starting.
+func (s *Service) StartRun(ctx context.Context, msg WsMessage) (string, string, *WsError) {
+	sess := s.newSession(ctx, nil, s.cfg.SSECfg.Grace)
+	sess.typed.Store(true)
+
+	msg.Type = MsgRun
+	if msg.RunID == "" {
+		msg.RunID = uuid.NewString()
+	}
+	werr, err := sess.call(msg)
+	if werr == nil && err != nil {
+		werr = &WsError{Code: ErrInvalidMessage, Message: err.Error()}
+	}
+	if werr != nil {
+		sess.close()
+		return "", "", werr
+	}
+
+	// nobody listens yet, the session waits for Subscribe as for a resume
+	sess.detach(nil, nil)
+	s.logger.Info("Started run over HTTP", map[string]any{"session_id": sess.id, "run_id": msg.RunID})
+	return sess.id, msg.RunID, nil
+}
+
+// Control sends an input, eof or stop message to the run of session sessionID.
+func (s *Service) Control(sessionID string, msg WsMessage) *WsError {
+	sess, werr := s.lookupSession(sessionID)
+	if werr != nil {
+		return werr
+	}
+	werr, _ = sess.call(msg)
+	return werr
+}
+
+// Subscribe attaches a new event stream to session sessionID, starting with the events after lastSeq.
+// A stream the session was attached to before is closed.
+func (s *Service) Subscribe(sessionID string, lastSeq uint64) (*EventStream, *WsError) {
+	sess, werr := s.lookupSession(sessionID)
+	if werr != nil {
+		return nil, werr
+	}
+
+	stream := &EventStream{
+		sess:   sess,
+		events: make(chan WsEvent, max(s.cfg.WsCfg.QueueSize, 1)),
+		done:   make(chan struct{}),
+	}
+	replay, ok := sess.attach(stream, WsMessage{LastSeq: lastSeq})
+	if !ok {
+		return nil, sessionNotFound(sessionID)
+	}
+	// the replay may not fit in the queue of the stream, which is only read once it is returned
+	go replay()
+	return stream, nil
+}
+
+// Events returns the events of the stream, starting with the replayed ones.
+func (e *EventStream) Events() <-chan WsEvent {
+	return e.events
+}
+
+// Done is closed when the stream was replaced by another one.
+func (e *EventStream) Done() <-chan struct{} {
+	return e.done
+}
+
+// Finished reports whether ev is the last event of the stream: the exit of the session's last run.
+func (e *EventStream) Finished(ev WsEvent) bool {
+	return ev.Type == EventExit && e.sess.active() == 0
+}
+
+// Close detaches the stream from its session, which can be subscribed to again during its grace period.
+func (e *EventStream) Close() {
+	e.drop()
+	e.sess.detach(e, nil)
+}
+
+func (e *EventStream) send(v any) error {
+	ev, ok := v.(WsEvent)
+	if !ok {
+		return nil
+	}
+	select {
+	case e.events <- ev:
+		return nil
+	case <-e.done:
+		return errWriterClosed
+	}
+}
+
+func (e *EventStream) drop() {
+	e.once.Do(func() { close(e.done) })
+}
+
+func (s *Service) lookupSession(sessionID string) (*session, *WsError) {
+	s.sessionsMu.Lock()
+	sess, ok := s.sessions[sessionID]
+	s.sessionsMu.Unlock()
+	if !ok {
+		return nil, sessionNotFound(sessionID)
+	}
+	return sess, nil
+}
+
+func sessionNotFound(sessionID string) *WsError {
+	return &WsError{Code: ErrSessionNotFound, Message: fmt.Sprintf("Session '%s' does not exist or has expired", sessionID)}
+}
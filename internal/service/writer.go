@@ -0,0 +1,251 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+)
+
+// Policies applied by a connection's writer when its outbound queue is full.
+const (
+	// PolicyBlock makes the producer wait, which slows the execution down to the client's pace.
+	PolicyBlock = "block"
+	// PolicyDrop discards program output and tells the client how much was lost once it catches up.
+	// Other events are never dropped but wait for room in the queue.
+	PolicyDrop = "drop"
+	// PolicyDisconnect closes the connection; typed clients can resume their session afterwards.
+	PolicyDisconnect = "disconnect"
+)
+
+var (
+	errWriterClosed = errors.New("connection writer is closed")
+	errSlowClient   = errors.New("client does not read fast enough")
+)
+
+// wsWriter owns the writing side of one WebSocket connection. Messages are queued by send and
+// written by a dedicated goroutine, so a slow client never holds up anyone else. Program output
+// that piles up in the queue is coalesced into fewer, larger messages. The goroutine also pings
+// the client every WsCfg.PingInterval.
+type wsWriter struct {
+	conn        *websocket.Conn
+	subprotocol string
+	cfg         *config.WebSocket
+	logger      *lgg.Logger
+
+	queue   chan any
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// newWriter starts the writer of conn.
+func newWriter(conn *websocket.Conn, cfg *config.WebSocket, logger *lgg.Logger) *wsWriter {
+	w := &wsWriter{
+		conn:        conn,
+		subprotocol: conn.Subprotocol(),
+		cfg:         cfg,
+		logger:      logger,
+		queue:       make(chan any, max(cfg.QueueSize, 1)),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// send queues v (a WsEvent or WsResponse) for writing, applying the slow client policy when the queue is full.
+func (w *wsWriter) send(v any) error {
+	select {
+	case <-w.quit:
+		return errWriterClosed
+	case <-w.done:
+		return errWriterClosed
+	case w.queue <- v:
+		return nil
+	default:
+	}
+
+	switch policy := w.cfg.SlowClientPolicy; {
+	case policy == PolicyDrop && isOutput(v):
+		w.dropped.Add(1)
+		return nil
+	case policy == PolicyDisconnect:
+		w.logger.Warn("Disconnecting slow WebSocket client", map[string]any{"queued": len(w.queue)})
+		w.closeWith(websocket.ClosePolicyViolation, "client too slow")
+		return errSlowClient
+	default:
+		select {
+		case w.queue <- v:
+			return nil
+		case <-w.quit:
+			return errWriterClosed
+		case <-w.done:
+			return errWriterClosed
+		}
+	}
+}
+
+// closeWith closes the connection right away, telling the client why with a close frame.
+func (w *wsWriter) closeWith(code int, reason string) {
+	w.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	w.conn.Close()
+}
+
+// drop closes the connection without further ado.
+func (w *wsWriter) drop() {
+	w.conn.Close()
+}
+
+// close writes what is still queued and closes the connection.
+func (w *wsWriter) close() {
+	w.once.Do(func() { close(w.quit) })
+	<-w.done
+}
+
+func (w *wsWriter) run() {
+	defer func() {
+		w.conn.Close()
+		close(w.done)
+	}()
+
+	var ping <-chan time.Time
+	if w.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(w.cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-ping:
+			if err := w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.cfg.WriteTimeout)); err != nil {
+				w.logger.Warn("Error pinging WebSocket", map[string]any{"error": err})
+				return
+			}
+		case v := <-w.queue:
+			if !w.flush(v) {
+				return
+			}
+		case <-w.quit:
+			for {
+				select {
+				case v := <-w.queue:
+					if !w.flush(v) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes v, merged with the output queued right behind it, reporting whether the connection is still usable.
+func (w *wsWriter) flush(v any) bool {
+	for {
+		var next any
+		select {
+		case next = <-w.queue:
+		default:
+		}
+		if next == nil {
+			break
+		}
+		merged, ok := coalesce(v, next, w.cfg.CoalesceBytes)
+		if !ok {
+			if !w.write(v) {
+				return false
+			}
+			v = next
+			continue
+		}
+		v = merged
+	}
+	return w.write(v)
+}
+
+func (w *wsWriter) write(v any) bool {
+	if n := w.dropped.Swap(0); n > 0 {
+		if !w.writeMessage(dropNotice(v, n)) {
+			return false
+		}
+	}
+	return w.writeMessage(v)
+}
+
+// writeMessage writes v in the encoding of the connection's subprotocol.
+func (w *wsWriter) writeMessage(v any) bool {
+	msgType, payload, err := encodeMessage(w.subprotocol, v)
+	if err != nil {
+		w.logger.Error("Error encoding WebSocket message", map[string]any{"error": err})
+		return true
+	}
+	w.conn.SetWriteDeadline(time.Now().Add(w.cfg.WriteTimeout))
+	if err := w.conn.WriteMessage(msgType, payload); err != nil {
+		w.logger.Error("Error writing to WebSocket", map[string]any{"error": err})
+		return false
+	}
+	return true
+}
+
+// dropNotice tells the client that n messages were dropped, in the protocol of the message v about to be written.
+func dropNotice(v any, n int64) any {
+	message := fmt.Sprintf("%d message(s) were dropped because the client did not read fast enough", n)
+	if _, ok := v.(WsEvent); ok {
+		ev := errorEvent("", ErrEventsDropped, message)
+		ev.V = ProtocolVersion
+		return ev
+	}
+	return WsResponse{Output: message, Status: "ERROR", Stream: StreamGateway, Error: &WsError{Code: ErrEventsDropped, Message: message}}
+}
+
+// coalesce merges the program output b into a when both come from the same stream of the same run
+// and the result stays within limit bytes. The merged message keeps the sequence number and the
+// prompt status of b.
+func coalesce(a, b any, limit int) (any, bool) {
+	switch x := a.(type) {
+	case WsEvent:
+		y, ok := b.(WsEvent)
+		if !ok || x.Type != EventOutput || y.Type != EventOutput || x.Status != "" ||
+			x.ID != y.ID || x.RunID != y.RunID || x.Stream != y.Stream || len(x.Output)+len(y.Output) > limit {
+			return nil, false
+		}
+		y.Output = x.Output + y.Output
+		return y, true
+	case WsResponse:
+		y, ok := b.(WsResponse)
+		if !ok || !legacyOutput(x) || !legacyOutput(y) || x.Status == "WAITING_FOR_INPUT" ||
+			x.Stream != y.Stream || len(x.Output)+len(y.Output) > limit {
+			return nil, false
+		}
+		y.Output = x.Output + y.Output
+		return y, true
+	default:
+		return nil, false
+	}
+}
+
+// isOutput reports whether v carries program output.
+func isOutput(v any) bool {
+	switch x := v.(type) {
+	case WsEvent:
+		return x.Type == EventOutput
+	case WsResponse:
+		return legacyOutput(x)
+	default:
+		return false
+	}
+}
+
+// legacyOutput reports whether resp carries program output.
+func legacyOutput(resp WsResponse) bool {
+	return (resp.Stream == StreamStdout || resp.Stream == StreamStderr) && resp.Error == nil && resp.ExitInfo == nil
+}
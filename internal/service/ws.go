@@ -0,0 +1,495 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/catalog"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExecuteWithWs handles WebSocket connections and routes code execution to the appropriate language service.
+// Executions belong to a session that outlives the connection for a grace period, so that a client
+// speaking the typed protocol can reconnect with a resume message and carry on.
+// The connection is closed when the client stops answering pings, sends a message larger than
+// WsCfg.MaxMessageBytes, or neither sends messages nor has executions for WsCfg.IdleTimeout.
+func (s *Service) ExecuteWithWs(ctx context.Context, conn *websocket.Conn, sessionID string) error {
+	cfg := s.cfg.WsCfg
+	out := newWriter(conn, cfg, s.logger)
+	defer out.close()
+
+	sess := s.newSession(ctx, out, cfg.ResumeGrace)
+	s.logger.Info("Opened WebSocket session", map[string]any{"connection_id": sessionID, "session_id": sess.id})
+
+	conn.SetReadLimit(cfg.MaxMessageBytes)
+	alive := func() {
+		if cfg.PongTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+		}
+	}
+	alive()
+	conn.SetPongHandler(func(string) error {
+		alive()
+		return nil
+	})
+
+	// the idle timer only closes connections whose session has no executions
+	var current atomic.Pointer[session]
+	current.Store(sess)
+	var idle *time.Timer
+	if cfg.IdleTimeout > 0 {
+		idle = time.NewTimer(cfg.IdleTimeout)
+		defer idle.Stop()
+		go func() {
+			for {
+				select {
+				case <-idle.C:
+					if current.Load().active() > 0 {
+						idle.Reset(cfg.IdleTimeout)
+						continue
+					}
+					s.logger.Info("Closing idle WebSocket", map[string]any{"session_id": current.Load().id})
+					out.closeWith(websocket.CloseNormalClosure, "idle timeout")
+					return
+				case <-out.done:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		msgType, payload, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				out.closeWith(websocket.CloseGoingAway, "keepalive timeout")
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
+				s.logger.Error("Error reading from WebSocket", map[string]any{"session_id": sess.id, "error": err})
+				if !sess.typed.Load() {
+					sess.emit(errorEvent("", ErrConnection, fmt.Sprintf("WebSocket read error: %v", err)))
+				}
+			} else {
+				s.logger.Warn("WebSocket closed", map[string]any{"session_id": sess.id, "error": err})
+				if !sess.typed.Load() {
+					sess.emit(WsEvent{Type: EventStatus, Status: "CLOSED", Output: "WebSocket connection closed"})
+				}
+			}
+			sess.detach(out, err)
+			return err
+		}
+		alive()
+		if idle != nil {
+			idle.Reset(cfg.IdleTimeout)
+		}
+
+		var msg WsMessage
+		switch {
+		case msgType == websocket.BinaryMessage && conn.Subprotocol() == SubprotocolMsgpack:
+			if err := decodeMsgpack(payload, &msg); err != nil {
+				s.logger.Warn("Invalid MessagePack message", map[string]any{"session_id": sess.id, "error": err})
+				sess.emit(errorEvent("", ErrInvalidMessage, fmt.Sprintf("Invalid MessagePack: %v", err)))
+				continue
+			}
+		case msgType != websocket.TextMessage:
+			s.logger.Warn("Ignoring non-text message from WebSocket", map[string]any{"session_id": sess.id})
+			sess.emit(errorEvent("", ErrInvalidMessage, "Non-text message received"))
+			continue
+		default:
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				s.logger.Warn("Invalid JSON message", map[string]any{"session_id": sess.id, "error": err})
+				sess.emit(errorEvent("", ErrInvalidMessage, fmt.Sprintf("Invalid JSON: %v", err)))
+				continue
+			}
+		}
+		s.logger.Debug("Received WebSocket JSON message", map[string]any{"session_id": sess.id, "message": msg})
+
+		if msg.Type == MsgResume {
+			sess = s.resume(sess, out, msg)
+			current.Store(sess)
+			continue
+		}
+		if err := sess.handle(msg); err != nil {
+			sess.close()
+			return err
+		}
+	}
+}
+
+// handle dispatches one client message, after the previous one was handled. A returned error closes the connection.
+func (sess *session) handle(msg WsMessage) error {
+	sess.handleMu.Lock()
+	defer sess.handleMu.Unlock()
+
+	if msg.Type != "" {
+		sess.typed.Store(true)
+	} else {
+		msg.Type = sess.legacyType(msg)
+	}
+
+	if msg.V > ProtocolVersion {
+		sess.emit(errorEvent(msg.ID, ErrUnsupportedProtocol, fmt.Sprintf("Protocol version %d is not supported, the gateway speaks version %d", msg.V, ProtocolVersion)))
+		return nil
+	}
+
+	switch msg.Type {
+	case MsgRun:
+		if msg.Language == "" || msg.Code == "" {
+			sess.emit(errorEvent(msg.ID, ErrInvalidMessage, "A run message requires 'language' and 'code'"))
+			return nil
+		}
+		return sess.startRun(msg)
+	case MsgInput:
+		return sess.sendInput(msg)
+	case MsgStop:
+		sess.stopRun(msg)
+	case MsgEOF:
+		sess.closeInput(msg)
+	case MsgJudge:
+		sess.startJudge(msg)
+	case MsgPing:
+		sess.emit(WsEvent{Type: EventPong, ID: msg.ID})
+	case "":
+		sess.s.logger.Warn("Invalid or unexpected JSON message", map[string]any{"session_id": sess.id, "message": msg})
+		sess.emit(errorEvent(msg.ID, ErrInvalidMessage, "Invalid message. Send JSON with 'language' and 'code' or 'input' for active session."))
+	default:
+		sess.emit(errorEvent(msg.ID, ErrInvalidMessage, fmt.Sprintf("Unknown message type '%s'", msg.Type)))
+	}
+	return nil
+}
+
+// legacyType infers the intent of a legacy message from the fields that are set,
+// or returns "" if the message is not valid in the legacy protocol.
+func (sess *session) legacyType(msg WsMessage) string {
+	switch {
+	case msg.Language != "" && msg.Code != "":
+		return MsgRun
+	case msg.Stop:
+		return MsgStop
+	case msg.Input != "" && sess.active() > 0:
+		return MsgInput
+	case msg.EOF:
+		return MsgEOF
+	default:
+		return ""
+	}
+}
+
+// startRun submits the code of msg to its language's executor and starts forwarding the results.
+func (sess *session) startRun(msg WsMessage) error {
+	s := sess.s
+	s.logger.Info("Received new code submission", map[string]any{"session_id": sess.id, "language": msg.Language, "code_length": len(msg.Code)})
+
+	language, executor, version, werr := s.admit(sess.id, msg)
+	if werr != nil {
+		sess.emit(errorEvent(msg.ID, werr.Code, werr.Message))
+		if werr.Code == ErrDangerousCode {
+			return errors.New("unsafe code detected")
+		}
+		return nil
+	}
+
+	if !sess.typed.Load() {
+		// legacy clients cannot tell runs apart, a new submission replaces the previous one
+		sess.stopAll(ExitReplaced)
+	}
+
+	started := time.Now()
+	sessionID := uuid.NewString()
+	s.logger.Info("Generated new session ID for code submission", map[string]any{"session_id": sessionID})
+
+	runID := msg.RunID
+	if runID == "" {
+		runID = sessionID
+	}
+	sess.mu.Lock()
+	_, exists := sess.runs[runID]
+	if _, judging := sess.judges[runID]; judging {
+		exists = true
+	}
+	active := len(sess.runs) + len(sess.judges)
+	sess.mu.Unlock()
+	if exists {
+		sess.emit(errorEvent(msg.ID, ErrInvalidMessage, fmt.Sprintf("Run '%s' is already active", runID)))
+		return nil
+	}
+	if limit := s.cfg.WsCfg.MaxRuns; limit > 0 && active >= limit {
+		sess.emit(errorEvent(msg.ID, ErrTooManyRuns, fmt.Sprintf("At most %d executions may run at once on a connection", limit)))
+		return nil
+	}
+	sess.emit(WsEvent{Type: EventQueued, ID: msg.ID, RunID: runID, SessionID: sess.id})
+
+	req := &compiler_service.ExecuteRequest{
+		SessionId: sessionID,
+		Payload: &compiler_service.ExecuteRequest_Code{
+			Code: &compiler_service.Code{
+				Language:   language,
+				SourceCode: msg.Code,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(sess.ctx)
+	stream, err := executor.Open(ctx, req)
+	if err != nil {
+		cancel()
+		s.logger.Error("Failed to start gRPC stream", map[string]any{"session_id": sessionID, "language": language, "error": err})
+		ev := executorErrorEvent(msg.ID, language, err)
+		ev.RunID = runID
+		sess.emit(ev)
+		return nil
+	}
+	s.logger.Info("Started new gRPC stream", map[string]any{"session_id": sessionID, "language": language, "version": version})
+
+	r := &run{
+		id:        msg.ID,
+		runID:     runID,
+		sessionID: sessionID,
+		stream:    stream,
+		cancel:    cancel,
+		started:   started,
+		maxBytes:  int64(config.ForLanguage(s.cfg.RunCfg.OutputBytes, language)),
+		maxLines:  int64(config.ForLanguage(s.cfg.RunCfg.OutputLines, language)),
+	}
+	wall := config.ForLanguage(s.cfg.RunCfg.WallTime, language)
+	if sess.wallTime > 0 && (wall == 0 || sess.wallTime < wall) {
+		wall = sess.wallTime
+	}
+	r.startTimers(ctx, wall, config.ForLanguage(s.cfg.RunCfg.IdleTime, language))
+	sess.mu.Lock()
+	sess.runs[runID] = r
+	sess.mu.Unlock()
+
+	go sess.forward(r)
+	s.logger.Info("Sent code to gRPC", map[string]any{"session_id": sessionID, "language": language, "bytes": len(msg.Code)})
+
+	if msg.Stdin != "" {
+		if err := sess.writeInput(r, msg.ID, msg.Stdin); err != nil {
+			return nil
+		}
+	}
+	if msg.EOF {
+		sess.closeStdin(r, msg.ID)
+	}
+	return nil
+}
+
+// admit resolves the language of the submission msg and checks its code, returning the language,
+// the executor and version to run it on, or the error that keeps it from running.
+func (s *Service) admit(sessionID string, msg WsMessage) (string, CodeExecutor, string, *WsError) {
+	language := catalog.Resolve(msg.Language)
+	executor, version, err := s.executor(language, msg.Version)
+	if err != nil {
+		s.logger.Warn("Unsupported language", map[string]any{"session_id": sessionID, "language": msg.Language, "version": msg.Version})
+		return "", nil, "", &WsError{Code: ErrUnsupportedLanguage, Message: err.Error()}
+	}
+
+	for _, keyword := range s.dangerous[language] {
+		if strings.Contains(msg.Code, keyword) {
+			s.logger.Warn("Dangerous code detected", map[string]any{"session_id": sessionID, "language": language})
+			return "", nil, "", &WsError{Code: ErrDangerousCode, Message: "Dangerous script detected"}
+		}
+	}
+	return language, executor, version, nil
+}
+
+// sendInput forwards the input of msg to the current run, closing its stdin afterwards if msg asks for it.
+func (sess *session) sendInput(msg WsMessage) error {
+	r := sess.target(msg)
+	if r == nil {
+		return nil
+	}
+	if r.inputClosed {
+		sess.emit(r.errorEvent(msg.ID, ErrInputClosed, "The input of the execution was already closed"))
+		return nil
+	}
+
+	if err := sess.writeInput(r, msg.ID, msg.Input); err != nil {
+		return err
+	}
+	if msg.EOF {
+		sess.closeStdin(r, msg.ID)
+	}
+	return nil
+}
+
+// writeInput sends text to the program of r. On failure the run is abandoned.
+func (sess *session) writeInput(r *run, id, text string) error {
+	sess.s.logger.Info("Received input", map[string]any{"session_id": r.sessionID, "input": text})
+	req := &compiler_service.ExecuteRequest{
+		SessionId: r.sessionID,
+		Payload: &compiler_service.ExecuteRequest_Input{
+			Input: &compiler_service.Input{
+				InputText: text,
+			},
+		},
+	}
+	if err := r.send(req); err != nil {
+		sess.s.logger.Error("Failed to send input request to gRPC", map[string]any{"session_id": r.sessionID, "error": err})
+		sess.emit(r.errorEvent(id, ErrInputFailed, fmt.Sprintf("Failed to send input: %v", err)))
+		sess.finish(r)
+		return err
+	}
+	sess.s.logger.Info("Sent input to gRPC", map[string]any{"session_id": r.sessionID})
+	r.touch()
+	return nil
+}
+
+// stopRun terminates the targeted run or judge. Its exit event reports ExitStopped, and the
+// connection accepts a new submission right away.
+func (sess *session) stopRun(msg WsMessage) {
+	if sess.stopJudge(msg) {
+		return
+	}
+	r := sess.target(msg)
+	if r == nil {
+		return
+	}
+	sess.s.logger.Info("Stopping execution on client request", map[string]any{"session_id": r.sessionID})
+	r.stop(ExitStopped)
+	sess.finish(r)
+}
+
+// closeInput signals end of input to the program of the targeted run.
+func (sess *session) closeInput(msg WsMessage) {
+	r := sess.target(msg)
+	if r == nil {
+		return
+	}
+	sess.closeStdin(r, msg.ID)
+}
+
+// closeStdin half-closes r's stream, which the executor turns into EOF on the program's stdin.
+func (sess *session) closeStdin(r *run, id string) {
+	if r.inputClosed {
+		return
+	}
+	r.inputClosed = true
+	if err := r.closeSend(); err != nil {
+		sess.s.logger.Warn("Failed to close gRPC stream input", map[string]any{"session_id": r.sessionID, "error": err})
+		sess.emit(r.errorEvent(id, ErrInputFailed, fmt.Sprintf("Failed to close input: %v", err)))
+		return
+	}
+	sess.s.logger.Info("Closed input of gRPC stream", map[string]any{"session_id": r.sessionID})
+}
+
+// forward relays the responses of r's stream to the client until the stream ends.
+func (sess *session) forward(r *run) {
+	s := sess.s
+	defer func() {
+		s.logger.Info("gRPC stream reader stopped", map[string]any{"session_id": r.sessionID})
+		sess.finish(r)
+	}()
+
+	for {
+		resp, err := r.stream.Recv()
+		if err != nil {
+			// forget the run before reporting its end, so that a client reacting
+			// to the exit event finds the connection free for a new submission
+			sess.finish(r)
+			exit := WsEvent{Type: EventExit, ID: r.id, RunID: r.runID, ExitInfo: r.exitInfo()}
+			// a stopped stream may still end with EOF, as the local executor's does
+			if reason := r.stopReason(); reason != "" {
+				s.logger.Info("gRPC stream stopped", map[string]any{"session_id": r.sessionID, "reason": reason})
+				if limit := r.timeoutLimit(); reason == ExitTimeout && limit != nil {
+					sess.emit(WsEvent{Type: EventTimeout, ID: r.id, RunID: r.runID, Stream: StreamGateway, Output: timeoutMessage(limit), Limit: limit})
+				}
+				exit.Reason = reason
+			} else if err == io.EOF {
+				s.logger.Info("gRPC stream closed cleanly by server (EOF)", map[string]any{"session_id": r.sessionID})
+				exit.Reason = ExitCompleted
+			} else if status.Code(err) == codes.Canceled {
+				s.logger.Warn("gRPC stream cancelled", map[string]any{"session_id": r.sessionID})
+				exit.Reason = ExitCancelled
+			} else {
+				s.logger.Warn("Error receiving from gRPC stream", map[string]any{"session_id": r.sessionID, "error": err})
+				sess.emit(r.errorEvent(r.id, ErrStreamFailed, fmt.Sprintf("gRPC stream error: %v", err)))
+				exit.Reason = ExitFailed
+			}
+			sess.emit(exit)
+			return
+		}
+		r.observe(resp)
+		r.touch()
+		if r.truncated {
+			continue // the run is being stopped for exceeding its output limits
+		}
+
+		var ev WsEvent
+
+		switch payload := resp.Payload.(type) {
+		case *compiler_service.ExecuteResponse_Output:
+			ev = WsEvent{Type: EventOutput, ID: r.id, RunID: r.runID, Stream: StreamStdout, Output: payload.Output.OutputText}
+			s.logger.Info("Received Output", map[string]any{"session_id": r.sessionID, "output": payload.Output.OutputText})
+			if strings.HasSuffix(strings.TrimSpace(payload.Output.OutputText), ":") || strings.HasSuffix(strings.TrimSpace(payload.Output.OutputText), "?") {
+				ev.Status = "WAITING_FOR_INPUT"
+				s.logger.Info("Detected input prompt, set WAITING_FOR_INPUT", map[string]any{"session_id": r.sessionID})
+			}
+		case *compiler_service.ExecuteResponse_Error:
+			if strings.Contains(payload.Error.ErrorText, "--- Cleaned up") {
+				continue // Skip cleanup messages
+			}
+			ev = WsEvent{Type: EventOutput, ID: r.id, RunID: r.runID, Stream: StreamStderr, Output: payload.Error.ErrorText}
+			s.logger.Error("Received Error", map[string]any{"session_id": r.sessionID, "error": payload.Error.ErrorText})
+		case *compiler_service.ExecuteResponse_Status:
+			ev = WsEvent{Type: EventStatus, ID: r.id, RunID: r.runID, Status: payload.Status.State}
+			s.logger.Info("Received Status", map[string]any{"session_id": r.sessionID, "status": payload.Status.State})
+		default:
+			s.logger.Warn("Received unknown payload type from gRPC", map[string]any{"session_id": r.sessionID})
+			continue
+		}
+
+		if ev.Type != EventOutput {
+			sess.emit(ev)
+			continue
+		}
+		var limit *LimitInfo
+		if ev.Output, limit = r.clip(ev.Output); ev.Output != "" {
+			sess.emit(ev)
+		}
+		if limit != nil {
+			s.logger.Warn("Output limit exceeded, stopping run", map[string]any{"session_id": r.sessionID, "limit": limit.Name, "max": limit.Max})
+			sess.emit(WsEvent{
+				Type:   EventTruncated,
+				ID:     r.id,
+				RunID:  r.runID,
+				Stream: StreamGateway,
+				Output: fmt.Sprintf("Output truncated, the program exceeded the limit of %d %s", limit.Max, strings.TrimPrefix(limit.Name, "output_")),
+				Limit:  limit,
+			})
+			r.stop(ExitTruncated)
+		}
+	}
+}
+
+// timeoutMessage describes the time limit a run exceeded.
+func timeoutMessage(limit *LimitInfo) string {
+	d := time.Duration(limit.Max) * time.Millisecond
+	if limit.Name == LimitIdleTime {
+		return fmt.Sprintf("Execution timed out after %s without output or input", d)
+	}
+	return fmt.Sprintf("Execution timed out, the program exceeded the time limit of %s", d)
+}
+
+// executorErrorEvent describes a failure to start an execution on any backend of language.
+func executorErrorEvent(id, language string, err error) WsEvent {
+	ev := errorEvent(id, ErrExecutorUnavailable, fmt.Sprintf("Failed to connect to %s execution service: %v", language, err))
+	var failoverErr *FailoverError
+	if errors.As(err, &failoverErr) {
+		ev.Error.Attempts = failoverErr.Attempts
+	}
+	return ev
+}
@@ -0,0 +1,280 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakeexec"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/wstest"
+	"google.golang.org/grpc/codes"
+)
+
+func TestExecuteWithWs_StreamsOutput(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("hello\n"),
+		fakeexec.Delay(10*time.Millisecond),
+		fakeexec.Output("world\n"),
+		fakeexec.Status("EXECUTION_COMPLETE"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: `print("hello")`})
+
+	expect(t, c, "SUCCESS", "hello\n")
+	expect(t, c, "SUCCESS", "world\n")
+	expectClosed(t, c)
+
+	reqs := exec.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("executor received %d requests, want 1", len(reqs))
+	}
+	code := reqs[0].GetCode()
+	if code.GetLanguage() != "python" || code.GetSourceCode() != `print("hello")` {
+		t.Fatalf("executor received code %+v", code)
+	}
+}
+
+func TestExecuteWithWs_InputPrompt(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("Name:"),
+		fakeexec.AwaitInput(),
+		fakeexec.EchoInput("hi "),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: `print("hi", input("Name:"))`})
+	expect(t, c, "WAITING_FOR_INPUT", "Name:")
+
+	c.Send(service.WsMessage{Input: "bob"})
+	expect(t, c, "SUCCESS", "hi bob")
+	expectClosed(t, c)
+
+	reqs := exec.Requests()
+	if len(reqs) != 2 || reqs[1].GetInput().GetInputText() != "bob" {
+		t.Fatalf("executor received %v, want code followed by input", reqs)
+	}
+	if reqs[0].SessionId == "" || reqs[1].SessionId != reqs[0].SessionId {
+		t.Fatalf("input was sent with session %q, code with %q", reqs[1].SessionId, reqs[0].SessionId)
+	}
+}
+
+func TestExecuteWithWs_ExecutorErrors(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Stderr("Traceback: ZeroDivisionError"),
+		fakeexec.Stderr("--- Cleaned up session ---"),
+		fakeexec.Output("after\n"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "1/0"})
+	expect(t, c, "ERROR", "ZeroDivisionError")
+	// the cleanup notice is not forwarded
+	expect(t, c, "SUCCESS", "after")
+	expectClosed(t, c)
+}
+
+func TestExecuteWithWs_StreamFailure(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("partial"),
+		fakeexec.Fail(codes.Internal, "executor crashed"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "partial")
+	expect(t, c, "ERROR", "executor crashed")
+	expect(t, c, "STREAM_CLOSED", "")
+}
+
+func TestExecuteWithWs_AbruptDisconnect(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Output("before"),
+		fakeexec.Delay(50*time.Millisecond),
+		fakeexec.Disconnect(),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "before")
+	expect(t, c, "ERROR", "gRPC stream error")
+	expect(t, c, "STREAM_CLOSED", "")
+}
+
+func TestExecuteWithWs_ClientDisconnectCancelsStream(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("running"), fakeexec.Hang())
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "while True: pass"})
+	expect(t, c, "SUCCESS", "running")
+
+	c.Close()
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestExecuteWithWs_UnsupportedLanguageKeepsConnection(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("ok"))
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "brainfuck", Code: "+++"})
+	expect(t, c, "ERROR", "not supported")
+
+	c.Send(service.WsMessage{Language: "py", Code: "print('ok')"})
+	expect(t, c, "SUCCESS", "ok")
+	expectClosed(t, c)
+
+	if got := exec.Requests()[0].GetCode().GetLanguage(); got != "python" {
+		t.Fatalf("alias was sent to the executor as %q, want python", got)
+	}
+}
+
+func TestExecuteWithWs_InvalidMessages(t *testing.T) {
+	c := newGateway(t, fakeexec.NewServer(t))
+
+	c.SendRaw("{not json")
+	expect(t, c, "ERROR", "Invalid JSON")
+
+	c.Send(service.WsMessage{Input: "no active run"})
+	expect(t, c, "ERROR", "Invalid message")
+}
+
+func TestExecuteWithWs_DangerousCode(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "import os\nos.system('rm -rf /')"})
+	expect(t, c, "ERROR", "Dangerous script detected")
+
+	if n := exec.Sessions(); n != 0 {
+		t.Fatalf("dangerous code reached the executor (%d sessions)", n)
+	}
+}
+
+func TestExecuteWithWs_FailoverToHealthyBackend(t *testing.T) {
+	down := fakeexec.NewServer(t)
+	down.Down()
+	up := fakeexec.NewServer(t)
+	up.SetScript(fakeexec.Output("from backup"))
+	c := newGateway(t, down, up)
+
+	for range 2 {
+		c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+		expect(t, c, "SUCCESS", "from backup")
+		expectClosed(t, c)
+	}
+}
+
+func TestExecuteWithWs_AllBackendsDown(t *testing.T) {
+	first, second := fakeexec.NewServer(t), fakeexec.NewServer(t)
+	first.Down()
+	second.Down()
+	c := newGateway(t, first, second)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	resp := expect(t, c, "ERROR", "Failed to connect")
+	if resp.Error == nil || resp.Error.Code != "EXECUTOR_UNAVAILABLE" || resp.Error.Attempts == 0 {
+		t.Fatalf("got error %+v, want EXECUTOR_UNAVAILABLE with attempts", resp.Error)
+	}
+
+	// the connection stays usable
+	c.Send(service.WsMessage{Language: "ruby", Code: "puts 1"})
+	expect(t, c, "ERROR", "not supported")
+}
+
+func TestExecuteWithWs_RoutesVersions(t *testing.T) {
+	old, current := fakeexec.NewServer(t), fakeexec.NewServer(t)
+	old.SetScript(fakeexec.Output("3.8"))
+	current.SetScript(fakeexec.Output("3.12"))
+
+	v38 := fakeexec.Executor("python", old)
+	v38.Version = "3.8"
+	v312 := fakeexec.Executor("python", current)
+	v312.Version = "3.12"
+	gw := testutil.NewGateway(t, testutil.Config(), fakeexec.NewRegistry(v38, v312))
+	c := wstest.Dial(t, gw.ExecuteURL(), nil)
+
+	c.Send(service.WsMessage{Language: "python", Version: "3.8", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "3.8")
+	expectClosed(t, c)
+
+	// the newest version is the default
+	c.Send(service.WsMessage{Language: "python", Code: "print(1)"})
+	expect(t, c, "SUCCESS", "3.12")
+	expectClosed(t, c)
+
+	c.Send(service.WsMessage{Language: "python", Version: "2.7", Code: "print 1"})
+	expect(t, c, "ERROR", "not supported")
+}
+
+func TestExecuteWithWs_LegacyStop(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.Output("running"), fakeexec.Hang())
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "while True: pass"})
+	expect(t, c, "SUCCESS", "running")
+
+	c.Send(service.WsMessage{Stop: true})
+	expect(t, c, "STOPPED", "Execution stopped")
+	expect(t, c, "STREAM_CLOSED", "")
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
+
+func TestExecuteWithWs_LegacyExitInfo(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(
+		fakeexec.Stderr("boom"),
+		fakeexec.Status(service.StateExitCode+"2"),
+	)
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "raise SystemExit(2)"})
+	if resp := expect(t, c, "ERROR", "boom"); resp.Stream != service.StreamStderr {
+		t.Fatalf("got %+v, want stderr stream", resp)
+	}
+	expect(t, c, service.StateExitCode+"2", "")
+	expect(t, c, "INFO", "closed by server")
+	resp := expect(t, c, "STREAM_CLOSED", "")
+	if resp.ExitInfo == nil || resp.ExitCode == nil || *resp.ExitCode != 2 {
+		t.Fatalf("got %+v, want exit code 2", resp.ExitInfo)
+	}
+}
+
+func TestExecuteWithWs_LegacyEOF(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScript(fakeexec.ReadToEOF(), fakeexec.EchoInput("read: "))
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "print(sys.stdin.read())"})
+	c.Send(service.WsMessage{Input: "x", EOF: true})
+	expect(t, c, "SUCCESS", "read: x")
+	expectClosed(t, c)
+}
+
+func TestExecuteWithWs_LegacySubmissionReplacesRun(t *testing.T) {
+	exec := fakeexec.NewServer(t)
+	exec.SetScriptFunc(func(code *compiler_service.Code) []fakeexec.Step {
+		if code.GetSourceCode() == "first" {
+			return []fakeexec.Step{fakeexec.Output("first"), fakeexec.Hang()}
+		}
+		return []fakeexec.Step{fakeexec.Output("second")}
+	})
+	c := newGateway(t, exec)
+
+	c.Send(service.WsMessage{Language: "python", Code: "first"})
+	expect(t, c, "SUCCESS", "first")
+
+	c.Send(service.WsMessage{Language: "python", Code: "second"})
+	expect(t, c, "SUCCESS", "second")
+	expectClosed(t, c)
+	exec.WaitIdle(wstest.DefaultTimeout)
+}
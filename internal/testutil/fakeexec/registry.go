@@ -0,0 +1,63 @@
+package fakeexec
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+)
+
+// Registry is a static repos.ExecutorRegistry whose executors can be replaced by tests.
+type Registry struct {
+	mu        sync.Mutex
+	executors []repos.Executor
+	listeners []func([]repos.Executor)
+}
+
+// NewRegistry returns a registry serving executors.
+func NewRegistry(executors ...repos.Executor) *Registry {
+	return &Registry{executors: executors}
+}
+
+// Executor is a shorthand for a default version executor of language served by servers.
+func Executor(language string, servers ...*Server) repos.Executor {
+	executor := repos.Executor{Language: language, DisplayName: language}
+	for _, s := range servers {
+		executor.Backends = append(executor.Backends, s.Backend())
+	}
+	return executor
+}
+
+func (r *Registry) Executors() []repos.Executor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.executors)
+}
+
+func (r *Registry) OnChange(fn func([]repos.Executor)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+func (r *Registry) Reload(context.Context) error {
+	return nil
+}
+
+func (r *Registry) Check(*models.Language) error {
+	return nil
+}
+
+// Set replaces the executors and notifies subscribers.
+func (r *Registry) Set(executors ...repos.Executor) {
+	r.mu.Lock()
+	r.executors = executors
+	listeners := slices.Clone(r.listeners)
+	r.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(slices.Clone(executors))
+	}
+}
@@ -0,0 +1,379 @@
+// Package fakeexec provides an in-process CodeExecutor gRPC server whose responses are scripted,
+// for testing the gateway without real executors.
+package fakeexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/genprotos/genprotos/compiler_service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Step is one scripted action of the fake executor. Build steps with the constructors below.
+type Step struct {
+	delay      time.Duration
+	response   *compiler_service.ExecuteResponse
+	awaitInput bool
+	readToEOF  bool
+	echoPrefix *string
+	byInput    func(input string) []Step
+	err        error
+	disconnect bool
+	hang       bool
+}
+
+// Output sends text as program stdout.
+func Output(text string) Step {
+	return Step{response: &compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Output{Output: &compiler_service.Output{OutputText: text}}}}
+}
+
+// Stderr sends text as an executor error payload.
+func Stderr(text string) Step {
+	return Step{response: &compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Error{Error: &compiler_service.Error{ErrorText: text}}}}
+}
+
+// Status sends a status update with the given state.
+func Status(state string) Step {
+	return Step{response: &compiler_service.ExecuteResponse{Payload: &compiler_service.ExecuteResponse_Status{Status: &compiler_service.Status{State: state}}}}
+}
+
+// Delay pauses the script.
+func Delay(d time.Duration) Step {
+	return Step{delay: d}
+}
+
+// AwaitInput blocks until the gateway sends the next input message (or half-closes the stream).
+func AwaitInput() Step {
+	return Step{awaitInput: true}
+}
+
+// ReadToEOF receives input messages until the gateway half-closes the stream.
+// The concatenation of everything received becomes the last input for EchoInput.
+func ReadToEOF() Step {
+	return Step{readToEOF: true}
+}
+
+// EchoInput sends prefix followed by the last received input as stdout.
+func EchoInput(prefix string) Step {
+	return Step{echoPrefix: &prefix}
+}
+
+// ByInput plays the steps chosen from the last received input.
+func ByInput(fn func(input string) []Step) Step {
+	return Step{byInput: fn}
+}
+
+// Fail ends the stream with a gRPC status error.
+func Fail(code codes.Code, msg string) Step {
+	return Step{err: status.Error(code, msg)}
+}
+
+// Disconnect abruptly closes every transport connection of the server.
+func Disconnect() Step {
+	return Step{disconnect: true}
+}
+
+// Hang blocks until the gateway cancels the stream.
+func Hang() Step {
+	return Step{hang: true}
+}
+
+// Server is a scriptable CodeExecutor served over an in-memory bufconn listener.
+type Server struct {
+	compiler_service.UnimplementedCodeExecutorServer
+
+	t        testing.TB
+	listener *trackingListener
+	grpc     *grpc.Server
+	health   *health.Server
+	conn     *grpc.ClientConn
+	address  string
+
+	mu       sync.Mutex
+	script   func(code *compiler_service.Code) []Step
+	requests []*compiler_service.ExecuteRequest
+	sessions int
+	active   int
+	closed   bool
+	changed  chan struct{}
+}
+
+// NewServer starts a fake executor that is stopped when the test ends.
+// Until SetScript is called every execution ends right after the code is received.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		t:        t,
+		listener: &trackingListener{Listener: bufconn.Listen(1 << 20)},
+		grpc:     grpc.NewServer(),
+		health:   health.NewServer(),
+		script:   func(*compiler_service.Code) []Step { return nil },
+		changed:  make(chan struct{}),
+	}
+	s.address = fmt.Sprintf("fakeexec-%p", s)
+	compiler_service.RegisterCodeExecutorServer(s.grpc, s)
+	grpc_health_v1.RegisterHealthServer(s.grpc, s.health)
+	go s.grpc.Serve(s.listener)
+
+	conn, err := grpc.NewClient("passthrough:///"+s.address,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil, errors.New("fake executor is down")
+			}
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("fakeexec: dial: %v", err)
+	}
+	s.conn = conn
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.grpc.Stop()
+	})
+	return s
+}
+
+// SetScript makes every following execution play steps.
+func (s *Server) SetScript(steps ...Step) {
+	s.SetScriptFunc(func(*compiler_service.Code) []Step { return steps })
+}
+
+// SetScriptFunc chooses the steps of every following execution from the submitted code.
+func (s *Server) SetScriptFunc(fn func(code *compiler_service.Code) []Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = fn
+}
+
+// Down makes the server refuse new connections and drops the existing ones, like a crashed backend.
+func (s *Server) Down() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.listener.closeConns()
+}
+
+// SetServing changes the status reported by the gRPC health service.
+func (s *Server) SetServing(serving bool) {
+	st := grpc_health_v1.HealthCheckResponse_SERVING
+	if !serving {
+		st = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	s.health.SetServingStatus("", st)
+}
+
+// Client returns a CodeExecutor client connected to the server.
+func (s *Server) Client() compiler_service.CodeExecutorClient {
+	return compiler_service.NewCodeExecutorClient(s.conn)
+}
+
+// Backend returns the server as a registry backend.
+func (s *Server) Backend() repos.Backend {
+	return repos.Backend{
+		Address: s.address,
+		Client:  s.Client(),
+		Health:  grpc_health_v1.NewHealthClient(s.conn),
+	}
+}
+
+// Requests returns every request received so far, in order.
+func (s *Server) Requests() []*compiler_service.ExecuteRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Sessions returns the number of execution streams opened so far.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+// WaitIdle waits until no execution stream is open anymore, failing the test after timeout.
+func (s *Server) WaitIdle(timeout time.Duration) {
+	s.t.Helper()
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		active, changed := s.active, s.changed
+		s.mu.Unlock()
+		if active == 0 {
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			s.t.Fatalf("fakeexec: %d execution(s) still open after %s", active, timeout)
+		}
+	}
+}
+
+// WaitOpen waits until n execution streams have been opened so far, failing the test after timeout.
+func (s *Server) WaitOpen(n int, timeout time.Duration) {
+	s.t.Helper()
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		sessions, changed := s.sessions, s.changed
+		s.mu.Unlock()
+		if sessions >= n {
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			s.t.Fatalf("fakeexec: %d of %d execution(s) opened after %s", sessions, n, timeout)
+		}
+	}
+}
+
+// Execute plays the script for the submitted code.
+func (s *Server) Execute(stream compiler_service.CodeExecutor_ExecuteServer) error {
+	s.track(1)
+	defer s.track(-1)
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.record(first)
+	code := first.GetCode()
+	if code == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry code")
+	}
+
+	s.mu.Lock()
+	steps := s.script(code)
+	s.mu.Unlock()
+
+	var lastInput string
+	for len(steps) > 0 {
+		step := steps[0]
+		steps = steps[1:]
+		if step.delay > 0 {
+			select {
+			case <-time.After(step.delay):
+			case <-stream.Context().Done():
+				return stream.Context().Err()
+			}
+		}
+		switch {
+		case step.response != nil:
+			resp := step.response
+			resp.SessionId = first.SessionId
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		case step.awaitInput:
+			req, err := stream.Recv()
+			if err == io.EOF {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			s.record(req)
+			lastInput = req.GetInput().GetInputText()
+		case step.readToEOF:
+			var all strings.Builder
+			for {
+				req, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				s.record(req)
+				all.WriteString(req.GetInput().GetInputText())
+			}
+			lastInput = all.String()
+		case step.echoPrefix != nil:
+			if err := stream.Send(&compiler_service.ExecuteResponse{
+				SessionId: first.SessionId,
+				Payload:   &compiler_service.ExecuteResponse_Output{Output: &compiler_service.Output{OutputText: *step.echoPrefix + lastInput}},
+			}); err != nil {
+				return err
+			}
+		case step.byInput != nil:
+			steps = append(step.byInput(lastInput), steps...)
+		case step.err != nil:
+			return step.err
+		case step.disconnect:
+			s.listener.closeConns()
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		case step.hang:
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		}
+	}
+	return nil
+}
+
+func (s *Server) record(req *compiler_service.ExecuteRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *Server) track(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delta > 0 {
+		s.sessions++
+	}
+	s.active += delta
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// trackingListener remembers accepted connections so that they can be dropped abruptly.
+type trackingListener struct {
+	*bufconn.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) closeConns() {
+	l.mu.Lock()
+	conns := l.conns
+	l.conns = nil
+	l.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
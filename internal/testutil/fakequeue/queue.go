@@ -0,0 +1,104 @@
+// Package fakequeue is an in-memory JobQueue for tests, with the delivery semantics of the Redis queue.
+package fakequeue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/models"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+)
+
+// Queue keeps jobs in memory. Jobs handed out by Next stay pending until Done; Requeue hands them
+// out again, as a gateway restarting or taking over another one's jobs would.
+type Queue struct {
+	mu      sync.Mutex
+	jobs    map[string]models.Job
+	queued  []string
+	pending map[string]bool
+}
+
+// New returns an empty queue.
+func New() *Queue {
+	return &Queue{jobs: make(map[string]models.Job), pending: make(map[string]bool)}
+}
+
+func (q *Queue) Push(ctx context.Context, job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = *job
+	q.queued = append(q.queued, job.ID)
+	return nil
+}
+
+func (q *Queue) Get(ctx context.Context, id string) (*models.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, repos.ErrJobNotFound
+	}
+	return &job, nil
+}
+
+func (q *Queue) Save(ctx context.Context, job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = *job
+	return nil
+}
+
+// Next polls for a queued job until ctx ends.
+func (q *Queue) Next(ctx context.Context) (*models.Job, error) {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		q.mu.Lock()
+		if len(q.queued) > 0 {
+			id := q.queued[0]
+			q.queued = q.queued[1:]
+			q.pending[id] = true
+			job := q.jobs[id]
+			q.mu.Unlock()
+			job.Receipt = id
+			return &job, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Touch does nothing: pending jobs are only handed out again by Requeue.
+func (q *Queue) Touch(ctx context.Context, job *models.Job) error {
+	return nil
+}
+
+func (q *Queue) Done(ctx context.Context, job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, job.Receipt)
+	return nil
+}
+
+// Pending returns the number of jobs handed out and not done.
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Requeue queues the pending jobs again.
+func (q *Queue) Requeue() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id := range q.pending {
+		q.queued = append(q.queued, id)
+		delete(q.pending, id)
+	}
+}
@@ -0,0 +1,142 @@
+// Package testutil wires a gateway around fake executors for end-to-end tests.
+package testutil
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/db"
+	handler "github.com/ruziba3vich/online_compiler_api_gateway/internal/http"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/middleware"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/repos"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/service"
+	"github.com/ruziba3vich/online_compiler_api_gateway/internal/testutil/fakequeue"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/config"
+	"github.com/ruziba3vich/online_compiler_api_gateway/pkg/lgg"
+	logger "github.com/ruziba3vich/prodonik_lgger"
+	limiter "github.com/ruziba3vich/prodonik_rl"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminToken is the admin API token of the test configuration.
+const AdminToken = "test-admin-token"
+
+// Logger returns a logger that discards everything.
+func Logger() *lgg.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return &lgg.Logger{Logger: log}
+}
+
+// Config returns the default configuration with timings shortened for tests.
+func Config() *config.Config {
+	cfg := config.NewConfig()
+	cfg.HealthCfg.Interval = 0
+	cfg.FailoverCfg.Backoff = time.Millisecond
+	cfg.FailoverCfg.MaxBackoff = 5 * time.Millisecond
+	cfg.RLCnfg.MaxTokens = 1 << 20
+	cfg.RLCnfg.Window = time.Minute
+	cfg.AdminToken = AdminToken
+	return cfg
+}
+
+// Redis starts an in-memory Redis server that is shut down when the test ends and returns a client of it.
+func Redis(t testing.TB) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+// Storage returns a language storage in a fresh database.
+func Storage(t testing.TB) repos.LanguageStorage {
+	t.Helper()
+	gormDB, err := db.NewDB(filepath.Join(t.TempDir(), "languages.db"))
+	if err != nil {
+		t.Fatalf("open language database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db.NewLanguageStorage(gormDB)
+}
+
+// Backends are what a gateway is built on. Registry is required; a gateway without a Queue
+// queues its jobs in memory and one without Storage stores its languages in a fresh database.
+type Backends struct {
+	Registry repos.ExecutorRegistry
+	Queue    repos.JobQueue
+	Storage  repos.LanguageStorage
+}
+
+// Gateway is a running gateway serving its API over an httptest server.
+type Gateway struct {
+	Service *service.Service
+	Jobs    *service.Jobs
+	Storage repos.LanguageStorage
+	Server  *httptest.Server
+}
+
+// NewGateway starts a gateway around registry that is shut down when the test ends.
+func NewGateway(t testing.TB, cfg *config.Config, registry repos.ExecutorRegistry) *Gateway {
+	t.Helper()
+	return NewGatewayWith(t, cfg, Backends{Registry: registry})
+}
+
+// NewGatewayWith starts a gateway on backends that is shut down when the test ends.
+// It serves the same routes as the gateway binary.
+func NewGatewayWith(t testing.TB, cfg *config.Config, backends Backends) *Gateway {
+	t.Helper()
+	if backends.Queue == nil {
+		backends.Queue = fakequeue.New()
+	}
+	if backends.Storage == nil {
+		backends.Storage = Storage(t)
+	}
+
+	log := Logger()
+	srv := service.NewService(log, backends.Registry, cfg)
+	srv.Start()
+	t.Cleanup(srv.Stop)
+	jobs := service.NewJobs(log, srv, backends.Queue, cfg)
+	jobs.Start()
+	t.Cleanup(jobs.Stop)
+
+	_, client := Redis(t)
+	rateLimiter := limiter.NewTokenBucketLimiter(client, cfg.RLCnfg.MaxTokens, cfg.RLCnfg.RefillRate, cfg.RLCnfg.Window)
+	mw := middleware.NewMidWare(&logger.Logger{Logger: log.Logger}, rateLimiter, cfg.AdminToken)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(
+		router,
+		handler.NewHandler(srv, log, cfg),
+		handler.NewLangHandler(srv, nil),
+		handler.NewAdminHandler(backends.Storage, backends.Registry, log),
+		handler.NewJobHandler(jobs, log),
+		mw,
+	)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return &Gateway{Service: srv, Jobs: jobs, Storage: backends.Storage, Server: server}
+}
+
+// URL returns the URL of path under /api/v1 on the gateway.
+func (g *Gateway) URL(path string) string {
+	return g.Server.URL + "/api/v1" + path
+}
+
+// ExecuteURL returns the WebSocket execution endpoint of the gateway.
+func (g *Gateway) ExecuteURL() string {
+	return g.URL("/execute")
+}
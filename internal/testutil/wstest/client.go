@@ -0,0 +1,95 @@
+// Package wstest provides a WebSocket client for tests against an httptest server.
+package wstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTimeout bounds how long Next waits for a message.
+const DefaultTimeout = 5 * time.Second
+
+// Client is a test WebSocket connection that fails the test on unexpected errors.
+type Client struct {
+	t        testing.TB
+	Conn     *websocket.Conn
+	Response *http.Response
+}
+
+// Dial connects to url, accepting http:// URLs as returned by httptest.Server.
+// The connection is closed when the test ends.
+func Dial(t testing.TB, url string, header http.Header) *Client {
+	t.Helper()
+	return DialWith(t, websocket.DefaultDialer, url, header)
+}
+
+// DialWith is Dial using dialer, e.g. to request subprotocols or compression.
+func DialWith(t testing.TB, dialer *websocket.Dialer, url string, header http.Header) *Client {
+	t.Helper()
+
+	url = "ws" + strings.TrimPrefix(url, "http")
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("wstest: dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &Client{t: t, Conn: conn, Response: resp}
+}
+
+// Send writes v as a JSON text message.
+func (c *Client) Send(v any) {
+	c.t.Helper()
+	if err := c.Conn.WriteJSON(v); err != nil {
+		c.t.Fatalf("wstest: send: %v", err)
+	}
+}
+
+// SendRaw writes payload as a text message.
+func (c *Client) SendRaw(payload string) {
+	c.t.Helper()
+	if err := c.Conn.WriteMessage(websocket.TextMessage, []byte(payload)); err != nil {
+		c.t.Fatalf("wstest: send: %v", err)
+	}
+}
+
+// Next returns the next message, failing the test if none arrives within DefaultTimeout.
+func (c *Client) Next() []byte {
+	c.t.Helper()
+	payload, err := c.TryNext(DefaultTimeout)
+	if err != nil {
+		c.t.Fatalf("wstest: read: %v", err)
+	}
+	return payload
+}
+
+// TryNext returns the next message or the read error, e.g. a timeout or a close frame.
+func (c *Client) TryNext(timeout time.Duration) ([]byte, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(timeout))
+	_, payload, err := c.Conn.ReadMessage()
+	return payload, err
+}
+
+// NextJSON decodes the next message into v.
+func (c *Client) NextJSON(v any) {
+	c.t.Helper()
+	payload := c.Next()
+	if err := json.Unmarshal(payload, v); err != nil {
+		c.t.Fatalf("wstest: decode %s: %v", payload, err)
+	}
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *Client) Close() {
+	c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.Conn.Close()
+}
+
+// Drop closes the connection without a close frame, like a client losing its network.
+func (c *Client) Drop() {
+	c.Conn.Close()
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"maps"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -10,15 +12,104 @@ import (
 
 type (
 	Config struct {
-		PythonService       string
-		JavaService         string
-		CppService          string
-		JsService           string
-		GatewayPort         string
-		LangStorageFilePath string
-		LogsFilePath        string
-		RLCnfg              *RateLimiter
-		RedisCfg            *RedisConfig
+		Executors              map[string]string
+		RegistryReloadInterval time.Duration
+		AdminToken             string
+		GatewayPort            string
+		LangStorageFilePath    string
+		LogsFilePath           string
+		RLCnfg                 *RateLimiter
+		RedisCfg               *RedisConfig
+		HealthCfg              *HealthCheck
+		FailoverCfg            *Failover
+		ExecutorTLS            *TLS
+		LocalExecCfg           *LocalExec
+		WsCfg                  *WebSocket
+		RunCfg                 *RunLimits
+		SSECfg                 *SSE
+		JobsCfg                *Jobs
+		JudgeCfg               *Judge
+	}
+
+	// Judge configures the judge messages, which run a submission against test cases
+	Judge struct {
+		Concurrency int           // test cases of a judge message run at once
+		MaxTests    int           // test cases accepted in a judge message
+		TimeLimit   time.Duration // wall time of a test case, compilation included; requests may lower it
+	}
+
+	// Jobs configures the background jobs, queued on a Redis stream shared by every gateway
+	Jobs struct {
+		Workers     int           // jobs run at once by this gateway, 0 to only accept jobs
+		Stream      string        // Redis stream of the queue, also prefixing the keys of the jobs
+		Consumer    string        // name of this gateway among the workers, kept across restarts
+		ClaimAfter  time.Duration // jobs their gateway has not touched for this long are taken over, 0 to never
+		MaxAttempts int           // a job given up by this many workers is failed
+		TTL         time.Duration // how long jobs are kept after their last update
+	}
+
+	// SSE configures the runs started over HTTP and streamed as server-sent events
+	SSE struct {
+		Grace     time.Duration // how long a run waits for its event stream to be (re)opened
+		KeepAlive time.Duration // between comments keeping idle event streams open, 0 to disable
+	}
+
+	// RunLimits bounds every execution; each limit maps a language to its value,
+	// with "*" for the languages not listed and 0 for no limit
+	RunLimits struct {
+		OutputBytes map[string]int
+		OutputLines map[string]int
+		WallTime    map[string]time.Duration // since the submission
+		IdleTime    map[string]time.Duration // without output from the program nor input from the client
+	}
+
+	// WebSocket configures the connections of the /execute endpoint
+	WebSocket struct {
+		MaxRuns      int           // concurrent executions per connection, 0 for no limit
+		ResumeGrace  time.Duration // how long executions outlive a lost connection, 0 to disable resuming
+		ResumeBuffer int           // events kept per resumable session for replay on resume
+
+		QueueSize        int           // outbound messages queued per connection
+		SlowClientPolicy string        // "block", "drop" or "disconnect" when the queue is full
+		CoalesceBytes    int           // queued output chunks are merged up to this size, 0 to disable
+		WriteTimeout     time.Duration // deadline of a single write
+
+		PingInterval    time.Duration // between keepalive pings, 0 to disable
+		PongTimeout     time.Duration // the connection is closed when the client sends nothing, not even a pong, for this long
+		MaxMessageBytes int64         // largest message accepted from the client
+		IdleTimeout     time.Duration // connections without executions nor messages are closed after it, 0 to keep them
+	}
+
+	// LocalExec limits programs run by the built-in local executor (addresses of the form "local://")
+	LocalExec struct {
+		WorkDir     string
+		WallTimeout time.Duration // 0 for no limit
+		CPUSeconds  int
+		MemoryMB    int
+		FileSizeKB  int
+		Namespaces  bool
+	}
+
+	// TLS holds the default transport security settings for seeded executors
+	TLS struct {
+		Enabled                   bool
+		CAFile, CertFile, KeyFile string
+		ServerName                string
+	}
+
+	// HealthCheck configures executor health probes and the per-backend circuit breaker
+	HealthCheck struct {
+		Interval         time.Duration
+		Timeout          time.Duration
+		FailureThreshold int
+		OpenTimeout      time.Duration
+	}
+
+	// Failover bounds the retries on other backends when opening an execution stream fails
+	Failover struct {
+		Attempts   int
+		Backoff    time.Duration
+		MaxBackoff time.Duration
 	}
 
 	RedisConfig struct {
@@ -36,13 +127,17 @@ type (
 func NewConfig() *Config {
 	_ = godotenv.Load()
 	return &Config{
-		PythonService:       getEnv("PYTHON_SERVICE", "108.181.201.147:702"),
-		JavaService:         getEnv("JAVA_SERVICE", "108.181.201.147:701"),
-		CppService:          getEnv("CPP_SERVICE", "108.181.201.147:703"),
-		JsService:           getEnv("JS_SERVICE", "108.181.201.147:704"),
-		GatewayPort:         getEnv("GATEWAY_PORT", "700"),
-		LangStorageFilePath: getEnv("LANG_STORAGE_FPATH", "data/languages.db"),
-		LogsFilePath:        getEnv("LOGS_FILE_PATH", "data/app.log"),
+		Executors: getEnvMap("EXECUTORS", map[string]string{
+			"python":     getEnv("PYTHON_SERVICE", "108.181.201.147:702"),
+			"java":       getEnv("JAVA_SERVICE", "108.181.201.147:701"),
+			"cpp":        getEnv("CPP_SERVICE", "108.181.201.147:703"),
+			"javascript": getEnv("JS_SERVICE", "108.181.201.147:704"),
+		}),
+		RegistryReloadInterval: getEnvParsedDuration("REGISTRY_RELOAD_INTERVAL", 10*time.Second),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		GatewayPort:            getEnv("GATEWAY_PORT", "700"),
+		LangStorageFilePath:    getEnv("LANG_STORAGE_FPATH", "data/languages.db"),
+		LogsFilePath:           getEnv("LOGS_FILE_PATH", "data/app.log"),
 		RedisCfg: &RedisConfig{
 			Host:     getEnv("REDIS_HOST", "redis"),
 			Port:     getEnv("REDIS_PORT", "6379"),
@@ -54,6 +149,70 @@ func NewConfig() *Config {
 			MaxTokens:  getEnvInt("MAX_TOKENS", 15),
 			Window:     getEnvDuration("RL_WINDOW", 1),
 		},
+		HealthCfg: &HealthCheck{
+			Interval:         getEnvParsedDuration("HEALTH_CHECK_INTERVAL", 10*time.Second),
+			Timeout:          getEnvParsedDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			FailureThreshold: getEnvInt("BREAKER_FAILURE_THRESHOLD", 3),
+			OpenTimeout:      getEnvParsedDuration("BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		},
+		ExecutorTLS: &TLS{
+			Enabled:    getEnvBool("EXECUTOR_TLS", false),
+			CAFile:     getEnv("EXECUTOR_TLS_CA_FILE", ""),
+			CertFile:   getEnv("EXECUTOR_TLS_CERT_FILE", ""),
+			KeyFile:    getEnv("EXECUTOR_TLS_KEY_FILE", ""),
+			ServerName: getEnv("EXECUTOR_TLS_SERVER_NAME", ""),
+		},
+		LocalExecCfg: &LocalExec{
+			WorkDir:     getEnv("LOCAL_EXEC_WORK_DIR", os.TempDir()),
+			WallTimeout: getEnvParsedDuration("LOCAL_EXEC_WALL_TIMEOUT", 30*time.Second),
+			CPUSeconds:  getEnvInt("LOCAL_EXEC_CPU_SECONDS", 10),
+			MemoryMB:    getEnvInt("LOCAL_EXEC_MEMORY_MB", 0),
+			FileSizeKB:  getEnvInt("LOCAL_EXEC_FILE_SIZE_KB", 10240),
+			Namespaces:  getEnvBool("LOCAL_EXEC_NAMESPACES", true),
+		},
+		WsCfg: &WebSocket{
+			MaxRuns:      getEnvInt("WS_MAX_RUNS", 4),
+			ResumeGrace:  getEnvParsedDuration("WS_RESUME_GRACE", 30*time.Second),
+			ResumeBuffer: getEnvInt("WS_RESUME_BUFFER", 1000),
+
+			QueueSize:        getEnvInt("WS_QUEUE_SIZE", 256),
+			SlowClientPolicy: getEnv("WS_SLOW_CLIENT_POLICY", "block"),
+			CoalesceBytes:    getEnvInt("WS_COALESCE_BYTES", 16384),
+			WriteTimeout:     getEnvParsedDuration("WS_WRITE_TIMEOUT", 5*time.Second),
+
+			PingInterval:    getEnvParsedDuration("WS_PING_INTERVAL", 25*time.Second),
+			PongTimeout:     getEnvParsedDuration("WS_PONG_TIMEOUT", 60*time.Second),
+			MaxMessageBytes: int64(getEnvInt("WS_MAX_MESSAGE_BYTES", 1<<20)),
+			IdleTimeout:     getEnvParsedDuration("WS_IDLE_TIMEOUT", 10*time.Minute),
+		},
+		RunCfg: &RunLimits{
+			OutputBytes: getEnvIntMap("RUN_MAX_OUTPUT_BYTES", map[string]int{"*": 1 << 20}),
+			OutputLines: getEnvIntMap("RUN_MAX_OUTPUT_LINES", map[string]int{"*": 10000}),
+			WallTime:    getEnvDurationMap("RUN_WALL_TIMEOUT", map[string]time.Duration{"*": 5 * time.Minute}),
+			IdleTime:    getEnvDurationMap("RUN_IDLE_TIMEOUT", map[string]time.Duration{"*": 2 * time.Minute}),
+		},
+		SSECfg: &SSE{
+			Grace:     getEnvParsedDuration("SSE_GRACE", time.Minute),
+			KeepAlive: getEnvParsedDuration("SSE_KEEPALIVE", 15*time.Second),
+		},
+		JobsCfg: &Jobs{
+			Workers:     getEnvInt("JOBS_WORKERS", 4),
+			Stream:      getEnv("JOBS_STREAM", "compiler:jobs"),
+			Consumer:    getEnv("JOBS_CONSUMER", hostname()),
+			ClaimAfter:  getEnvParsedDuration("JOBS_CLAIM_AFTER", 10*time.Minute),
+			MaxAttempts: getEnvInt("JOBS_MAX_ATTEMPTS", 3),
+			TTL:         getEnvParsedDuration("JOBS_TTL", 24*time.Hour),
+		},
+		JudgeCfg: &Judge{
+			Concurrency: getEnvInt("JUDGE_CONCURRENCY", 4),
+			MaxTests:    getEnvInt("JUDGE_MAX_TESTS", 100),
+			TimeLimit:   getEnvParsedDuration("JUDGE_TIME_LIMIT", 10*time.Second),
+		},
+		FailoverCfg: &Failover{
+			Attempts:   getEnvInt("FAILOVER_ATTEMPTS", 3),
+			Backoff:    getEnvParsedDuration("FAILOVER_BACKOFF", 100*time.Millisecond),
+			MaxBackoff: getEnvParsedDuration("FAILOVER_MAX_BACKOFF", time.Second),
+		},
 	}
 }
 
@@ -75,6 +234,16 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		v, err := strconv.ParseBool(value)
+		if err == nil {
+			return v
+		}
+	}
+	return fallback
+}
+
 func getEnvFloat64(key string, fallback float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		v, err := strconv.ParseFloat(value, 64)
@@ -93,3 +262,77 @@ func getEnvDuration(key string, fallback int) time.Duration {
 
 	return time.Duration(fallback) * time.Minute
 }
+
+// getEnvMap parses a comma separated list of key=value pairs (e.g. "python=host:702|host2:702,go=host:705")
+// and merges it over the fallback map, so entries can be added or overridden without repeating the defaults
+func getEnvMap(key string, fallback map[string]string) map[string]string {
+	result := make(map[string]string, len(fallback))
+	for k, v := range fallback {
+		result[k] = v
+	}
+
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if !ok || k == "" {
+			continue
+		}
+		if v == "" {
+			delete(result, k)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// getEnvIntMap parses a comma separated list of key=value pairs with integer values (e.g. "*=1024,python=4096")
+// and merges it over the fallback map
+func getEnvIntMap(key string, fallback map[string]int) map[string]int {
+	result := maps.Clone(fallback)
+	for k, v := range getEnvMap(key, nil) {
+		if n, err := strconv.Atoi(v); err == nil {
+			result[k] = n
+		}
+	}
+	return result
+}
+
+// getEnvDurationMap parses a comma separated list of key=value pairs with Go duration values (e.g. "*=30s,java=1m")
+// and merges it over the fallback map
+func getEnvDurationMap(key string, fallback map[string]time.Duration) map[string]time.Duration {
+	result := maps.Clone(fallback)
+	for k, v := range getEnvMap(key, nil) {
+		if d, err := time.ParseDuration(v); err == nil {
+			result[k] = d
+		}
+	}
+	return result
+}
+
+// ForLanguage returns the entry of language in a per-language map, or the "*" entry if there is none
+func ForLanguage[T any](values map[string]T, language string) T {
+	if v, ok := values[language]; ok {
+		return v
+	}
+	return values["*"]
+}
+
+// hostname names the gateway after its host, or "gateway" if the name is unknown
+func hostname() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "gateway"
+}
+
+// getEnvParsedDuration reads a Go duration string such as "10s" or "1m30s"
+func getEnvParsedDuration(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		v, err := time.ParseDuration(value)
+		if err == nil {
+			return v
+		}
+	}
+	return fallback
+}